@@ -21,17 +21,58 @@ func main() {
 	fileFlag := flag.String("file", "-", "file is to parse a single file. Will override a directory")
 	outFlag := flag.String("out", "./", "dir is to specify what folder to parse types to")
 	recursiveFlag := flag.Bool("r", true, "to recursively ascend all folders in dir")
+	langFlag := flag.String("lang", "flow", "lang is the emitter to write types with: flow, ts, jsonschema or openapi")
+	enumsFlag := flag.Bool("enums", false, "enums writes an object of the Go constant names next to each enum")
+	nonNullFlag := flag.Bool("nonnull-optionals", false, "nonnull-optionals writes omitempty pointers as name?: T rather than name?: ?T")
+	encodingJSONFlag := flag.Bool("encoding-json", false, "encoding-json writes every field encoding/json writes, including untagged ones")
+	hoistFlag := flag.Bool("hoist", false, "hoist writes anonymous structs as types of their own rather than inline")
+	tuplesFlag := flag.Bool("tuples", false, "tuples writes fixed size arrays as tuples rather than Array")
+	anyFlag := flag.Bool("any-interfaces", false, "any-interfaces writes interface{} and any as any rather than mixed")
+	numberKeysFlag := flag.Bool("number-keys", false, "number-keys writes integer map keys as number rather than string")
+	nullUnionsFlag := flag.Bool("null-unions", false, "null-unions writes nullable types as T | null rather than ?T")
+	nullCollectionsFlag := flag.Bool("nullable-collections", false, "nullable-collections writes slice and map fields without omitempty as nullable")
+	nonNullElementsFlag := flag.Bool("nonnull-elements", false, "nonnull-elements writes pointers within slices and maps as Array<T> rather than Array<?T>")
+	unserializableFlag := flag.String("unserializable", "skip", "unserializable is what to do with fields encoding/json can't marshal: skip, mixed or error")
+	guardsFlag := flag.Bool("guards", false, "guards writes a function checking a value is of each type at runtime, as in isPerson(x)")
+	typesFlag := flag.String("types", "", "types is a JSON file of well-known types to write as given, such as time.Time")
 	flag.Usage = usage
 	flag.Parse()
 
+	emitter, ok := parse.LookupEmitter(*langFlag)
+	if !ok {
+		log.WithField("lang", *langFlag).Fatalln("lang must be one of " + strings.Join(parse.Emitters(), ", "))
+	}
+	if *guardsFlag {
+		flow, ok := emitter.(parse.FlowEmitter)
+		if !ok {
+			log.WithField("lang", *langFlag).Fatalln("guards are only written for flow")
+		}
+		flow.Guards = true
+		emitter = flow
+	}
+	if openapi, ok := emitter.(parse.OpenAPIEmitter); ok {
+		openapi.YAML = strings.HasSuffix(*outFlag, ".yaml") || strings.HasSuffix(*outFlag, ".yml")
+		emitter = openapi
+	}
+	ext := emitter.Extension()
+	// .yml names a YAML file as much as .yaml does
+	if ext == ".yaml" && strings.HasSuffix(*outFlag, ".yml") {
+		ext = ".yml"
+	}
+
 	// Try to be smart about where to save
 	var out string
-	if strings.HasSuffix(*outFlag, ".js") {
+	if strings.HasSuffix(*outFlag, ext) {
 		out = *outFlag
 	} else if strings.HasSuffix(*outFlag, "/") {
-		out = *outFlag + "models.js"
+		out = *outFlag + "models" + ext
 	} else {
-		out = *outFlag + "/models.js"
+		out = *outFlag + "/models" + ext
+	}
+
+	if ts, ok := emitter.(parse.TypeScriptEmitter); ok {
+		ts.Declarations = strings.HasSuffix(out, ".d.ts")
+		emitter = ts
 	}
 
 	fi, err := os.Create(out)
@@ -41,6 +82,31 @@ func main() {
 	defer fi.Close()
 
 	p := parse.New(*recursiveFlag, fi)
+	p.Options.EnumObjects = *enumsFlag
+	p.Options.NonNullOptionals = *nonNullFlag
+	p.Options.MatchEncodingJSON = *encodingJSONFlag
+	p.Options.HoistStructs = *hoistFlag
+	p.Options.Tuples = *tuplesFlag
+	p.Options.AnyInterfaces = *anyFlag
+	p.Options.NumberKeys = *numberKeysFlag
+	p.Options.NullUnions = *nullUnionsFlag
+	p.Options.NullableCollections = *nullCollectionsFlag
+	p.Options.NonNullElements = *nonNullElementsFlag
+	switch *unserializableFlag {
+	case "skip":
+		p.Options.Unserializable = parse.SkipUnserializable
+	case "mixed":
+		p.Options.Unserializable = parse.MixedUnserializable
+	case "error":
+		p.Options.Unserializable = parse.FailUnserializable
+	default:
+		log.WithField("unserializable", *unserializableFlag).Fatalln("unserializable must be skip, mixed or error")
+	}
+	if *typesFlag != "" {
+		if err := p.WellKnown.Load(*typesFlag); err != nil {
+			log.WithError(err).Fatalln("error loading types")
+		}
+	}
 	spin.Start()
 
 	if *fileFlag != "-" {
@@ -48,22 +114,35 @@ func main() {
 			log.Error("the file passed in is not a go file.")
 			os.Exit(1)
 		}
-		p.Files = append(p.Files, *inFlag)
-		if err := p.ParseFiles(); err != nil {
-			log.WithError(err).Fatalln("error parsing file")
-		}
+		p.Files = append(p.Files, *fileFlag)
+		err = p.ParseFiles()
 	} else {
 		if err := p.ParseDir(*inFlag); err != nil {
 			log.WithError(err).Fatalln("error parsing directory")
 		}
-		if err := p.ParseFiles(); err != nil {
-			log.WithError(err).Fatalln("error parsing directory")
-		}
+		err = p.ParseFiles()
+	}
+
+	warn(p.Diagnostics)
+	if err != nil {
+		log.WithError(err).Fatalln("error parsing")
 	}
 
-	p.WriteDocument()
+	// Emitters diagnose what they can't write as well
+	parsed := len(p.Diagnostics)
+	if err := p.Emit(emitter); err != nil {
+		log.WithError(err).Fatalln("error writing")
+	}
+	warn(p.Diagnostics[parsed:])
 
 	spin.Stop()
 	log.WithField("save_location", out).Info("saved")
 	log.WithField("duration", time.Now().Sub(start)).Info("completed code generation")
 }
+
+// warn logs the types that could not be written exactly
+func warn(diagnostics []parse.Diagnostic) {
+	for _, d := range diagnostics {
+		log.WithFields(log.Fields{"type": d.Type, "position": d.Pos}).Warn(d.Message)
+	}
+}
@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+)
+
+// Diagnostic is a type that could not be written exactly as it goes over the wire
+type Diagnostic struct {
+	// Pos is where the type is declared
+	Pos token.Position
+
+	// Type is the package qualified name of the type
+	Type string
+
+	// Message explains what was written instead
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Type, d.Message)
+}
+
+// diagnose records a diagnostic about obj
+func (p *Parse) diagnose(obj types.Object, format string, args ...interface{}) {
+	name := obj.Name()
+	if obj.Pkg() != nil {
+		name = obj.Pkg().Path() + "." + name
+	}
+	p.diagnoseAt(obj.Pos(), name, format, args...)
+}
+
+// diagnoseAt records a diagnostic about a type that has no name of its own. Without a
+// position, it is placed at the field or type being resolved.
+func (p *Parse) diagnoseAt(pos token.Pos, name string, format string, args ...interface{}) {
+	if !pos.IsValid() {
+		pos = p.at
+	}
+	d := Diagnostic{Type: name, Message: fmt.Sprintf(format, args...)}
+	if p.fset != nil {
+		d.Pos = p.fset.Position(pos)
+	}
+	p.Diagnostics = append(p.Diagnostics, d)
+}
@@ -0,0 +1,251 @@
+package parse
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// Document is everything parsed, in the order it is written, for an Emitter to write
+type Document struct {
+	// Imports are the lines the well-known types written need at the top of the document
+	Imports []string
+
+	// Decls are the named types, base types first and then structs, each alphabetically
+	Decls []Decl
+
+	// Options are what the types were parsed with
+	Options Options
+
+	// Diagnostics are the types an Emitter could not write exactly, which Emit adds to those of the Parse
+	Diagnostics []Diagnostic
+
+	// objs are the Go types of the declarations by name, for their positions
+	objs map[string]*types.TypeName
+	fset *token.FileSet
+}
+
+// Diagnose records a diagnostic about a declaration an Emitter could not write exactly
+func (doc *Document) Diagnose(d Decl, format string, args ...interface{}) {
+	diag := Diagnostic{Type: d.Name, Message: fmt.Sprintf(format, args...)}
+	if obj := doc.objs[d.Name]; obj != nil {
+		if obj.Pkg() != nil {
+			diag.Type = obj.Pkg().Path() + "." + obj.Name()
+		}
+		if doc.fset != nil {
+			diag.Pos = doc.fset.Position(obj.Pos())
+		}
+	}
+	doc.Diagnostics = append(doc.Diagnostics, diag)
+}
+
+// Decl is a named type
+type Decl struct {
+	// Name is the name the type is written as
+	Name string
+
+	// Comment is the doc comment of the Go type, directives included
+	Comment string
+
+	// Directives are the @name value lines of the comment by name, as in strict or tstype
+	Directives map[string]string
+
+	// TypeParams are the type parameters of a generic type
+	TypeParams []TypeParam
+
+	// Type is what the type is written as. Structs are an object of their fields.
+	Type *Type
+
+	// Struct is set for Go structs, as opposed to the types that are written as another
+	Struct bool
+
+	// Enum are the Go constants of an enum, in the order they are declared
+	Enum []EnumValue
+}
+
+// Strict reports whether the type has a @strict directive, so objects may only have their own fields
+func (d Decl) Strict() bool {
+	_, ok := d.Directives["strict"]
+	return ok
+}
+
+// Type is a resolved Go type, independent of the language it is written in
+type Type struct {
+	Kind Kind
+
+	// Name is the primitive, the referenced type, the literal, the raw text, or the type parameter
+	Name string
+
+	// Integer is set for numbers that are Go integers
+	Integer bool
+
+	// Elem is the element of nullables, arrays, tuples and maps
+	Elem *Type
+
+	// Length is the length of a tuple
+	Length int64
+
+	// Key is the key of a map
+	Key *Type
+
+	// Args are the type arguments of an instantiated generic type
+	Args []*Type
+
+	// Members are the alternatives of a union
+	Members []*Type
+
+	// Comment is carried over next to a union member
+	Comment string
+
+	// Fields are the fields of an object
+	Fields []Field
+}
+
+// Field is a property of an object
+type Field struct {
+	// Name is the name the field is written under, from its json or flow tag
+	Name string
+
+	Type *Type
+
+	// Comment is the comment beside the Go field
+	Comment string
+
+	// Optional is set for fields that may be left out, such as those tagged omitempty
+	Optional bool
+}
+
+// TypeParam is a type parameter of a generic type
+type TypeParam struct {
+	Name string
+
+	// Bound is the type the parameter is limited to, or nil when it is anything
+	Bound *Type
+}
+
+// EnumValue is one of the Go constants of an enum
+type EnumValue struct {
+	// Name is the Go name of the constant
+	Name string
+
+	// Value is the constant written as a literal
+	Value string
+
+	// Comment is the Go comment of the constant
+	Comment string
+}
+
+// Document returns everything parsed, for an Emitter to write
+func (p *Parse) Document() *Document {
+	doc := &Document{Options: p.Options, objs: make(map[string]*types.TypeName), fset: p.fset}
+	for obj, name := range p.names {
+		doc.objs[name] = obj
+	}
+	for k := range p.imports {
+		doc.Imports = append(doc.Imports, k)
+	}
+	sort.Strings(doc.Imports)
+
+	// Sort the base types alphabetically
+	sortedBase := []string{}
+	for k := range p.baseMappings {
+		sortedBase = append(sortedBase, k)
+	}
+	sort.Strings(sortedBase)
+
+	// Sort the structs alphabetically
+	sortedStructs := []string{}
+	for k := range p.mappings {
+		sortedStructs = append(sortedStructs, k)
+	}
+	sort.Strings(sortedStructs)
+
+	for _, v := range sortedBase {
+		c := p.comments[v]
+		if strings.Contains(c, "// flowignore") {
+			continue
+		}
+		d := p.decl(v, c)
+		d.Type = export(p.baseMappings[v].typ)
+		if p.Options.EnumObjects {
+			for _, e := range p.enums[v] {
+				d.Enum = append(d.Enum, EnumValue{Name: e.name, Value: e.value, Comment: e.comment})
+			}
+		}
+		doc.Decls = append(doc.Decls, d)
+	}
+
+	for _, v := range sortedStructs {
+		c := p.comments[v]
+		if len(p.mappings[v]) == 0 || strings.Contains(c, "\n@flowignore\n") {
+			continue
+		}
+		d := p.decl(v, c)
+		d.Struct = true
+		d.Type = &Type{Kind: KindObject, Fields: exportFields(p.mappings[v])}
+		doc.Decls = append(doc.Decls, d)
+	}
+	return doc
+}
+
+// decl starts the declaration of a type with its comment and type parameters
+func (p *Parse) decl(name, comment string) Decl {
+	d := Decl{Name: name, Comment: comment, Directives: make(map[string]string)}
+	for _, line := range strings.Split(comment, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && len(fields[0]) > 1 && strings.HasPrefix(fields[0], "@") {
+			d.Directives[fields[0][1:]] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+		}
+	}
+	for _, tp := range p.typeParams[name] {
+		d.TypeParams = append(d.TypeParams, TypeParam{Name: tp.name, Bound: export(tp.bound)})
+	}
+	return d
+}
+
+// export turns a resolved type into its exported form
+func export(t *typeExpr) *Type {
+	if t == nil {
+		return nil
+	}
+	out := &Type{
+		Kind:    t.kind,
+		Name:    t.name,
+		Integer: t.integer,
+		Elem:    export(t.elem),
+		Length:  t.length,
+		Key:     export(t.key),
+		Comment: t.comment,
+		Fields:  exportFields(t.fields),
+	}
+	for _, a := range t.args {
+		out.Args = append(out.Args, export(a))
+	}
+	for _, m := range t.members {
+		out.Members = append(out.Members, export(m))
+	}
+	return out
+}
+
+// exportFields turns the fields of a struct into their exported form, under the name they are written as
+func exportFields(fields []field) []Field {
+	if fields == nil {
+		return nil
+	}
+	out := []Field{}
+	for _, f := range fields {
+		var name string
+		if f.tags.flow.name != "" {
+			name = f.tags.flow.name
+		} else if f.tags.json == "" {
+			// BUG: This is a patch to get it working. Not sure why this isn't being parsed earlier.
+			name = getTag("json", f.tags.original)
+		} else {
+			name = f.tags.json
+		}
+		out = append(out, Field{Name: name, Type: export(f.typ), Comment: f.comment, Optional: f.optional})
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package parse
+
+import (
+	"io"
+	"sort"
+	"sync"
+)
+
+// Emitter writes a parsed Document in an output language
+type Emitter interface {
+	// Extension is the file extension of what is written, as in .js
+	Extension() string
+
+	// Emit writes doc to w
+	Emit(w io.Writer, doc *Document) error
+}
+
+var (
+	emittersMu sync.RWMutex
+	emitters   = map[string]Emitter{
+		"flow":       FlowEmitter{},
+		"ts":         TypeScriptEmitter{},
+		"jsonschema": JSONSchemaEmitter{},
+		"openapi":    OpenAPIEmitter{},
+	}
+)
+
+// RegisterEmitter makes e available under name, which is how the -lang flag picks it.
+// An Emitter registered under a name already in use replaces it.
+func RegisterEmitter(name string, e Emitter) {
+	emittersMu.Lock()
+	defer emittersMu.Unlock()
+	emitters[name] = e
+}
+
+// LookupEmitter returns the Emitter registered under name
+func LookupEmitter(name string) (Emitter, bool) {
+	emittersMu.RLock()
+	defer emittersMu.RUnlock()
+	e, ok := emitters[name]
+	return e, ok
+}
+
+// Emitters returns the names of every registered Emitter, sorted
+func Emitters() []string {
+	emittersMu.RLock()
+	defer emittersMu.RUnlock()
+	names := []string{}
+	for name := range emitters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
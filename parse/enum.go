@@ -0,0 +1,138 @@
+package parse
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// enumValue is one of the constants declared for an enum type
+type enumValue struct {
+	// name is the Go name of the constant
+	name string
+
+	// value is the constant written as a literal
+	value string
+
+	// comment is the Go comment of the constant
+	comment string
+}
+
+// constDecl is a typed constant along with how it was declared
+type constDecl struct {
+	obj *types.Const
+
+	// iota is set when the constant is part of a group that uses iota
+	iota bool
+
+	// doc is the comment of the constant, above or beside it
+	doc *ast.CommentGroup
+}
+
+// constsOf returns the typed constants declared in a package, by their type, in the
+// order they were declared. Packages are only scanned once.
+func (p *Parse) constsOf(pkg *types.Package) map[*types.TypeName][]constDecl {
+	if consts, ok := p.consts[pkg.Path()]; ok {
+		return consts
+	}
+	consts := make(map[*types.TypeName][]constDecl)
+	p.consts[pkg.Path()] = consts
+
+	src := p.sourceOf(pkg)
+	for _, f := range src.files {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			withIota := false
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				// Constants without values repeat the expression before them, iota included
+				if len(vs.Values) > 0 {
+					withIota = usesIota(vs)
+				}
+				doc := docOf(gd, vs)
+				for _, id := range vs.Names {
+					c, ok := src.info.Defs[id].(*types.Const)
+					if !ok || c.Name() == "_" {
+						continue
+					}
+					named, ok := c.Type().(*types.Named)
+					if !ok {
+						continue
+					}
+					consts[named.Obj()] = append(consts[named.Obj()], constDecl{obj: c, iota: withIota, doc: doc})
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// enum returns the union of the values declared for obj, or nil if obj is not an enum.
+// String types are enums as soon as they have constants. Integer types are only enums
+// when their constants are counted with iota, which leaves units such as time.Duration
+// as numbers.
+func (p *Parse) enum(name string, obj *types.TypeName) *typeExpr {
+	basic, ok := obj.Type().Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsInteger|types.IsString) == 0 {
+		return nil
+	}
+
+	decls := p.constsOf(obj.Pkg())[obj]
+	if len(decls) == 0 {
+		return nil
+	}
+	if basic.Info()&types.IsInteger != 0 {
+		counted := false
+		for _, d := range decls {
+			counted = counted || d.iota
+		}
+		if !counted {
+			return nil
+		}
+	}
+
+	union := &typeExpr{kind: KindUnion}
+	values := []enumValue{}
+	seen := make(map[string]bool)
+	for _, d := range decls {
+		v := enumValue{name: d.obj.Name(), value: literal(d.obj.Val())}
+		if d.doc != nil {
+			v.comment = strings.Join(strings.Fields(d.doc.Text()), " ")
+		}
+		values = append(values, v)
+		if !seen[v.value] {
+			seen[v.value] = true
+			union.members = append(union.members, &typeExpr{kind: KindLiteral, name: v.value, comment: v.comment})
+		}
+	}
+	p.enums[name] = values
+	return union
+}
+
+// literal writes a constant as a JavaScript literal, with strings in single quotes
+func literal(v constant.Value) string {
+	if v.Kind() != constant.String {
+		return v.ExactString()
+	}
+	q := strconv.Quote(constant.StringVal(v))
+	q = strings.Replace(q[1:len(q)-1], `\"`, `"`, -1)
+	return "'" + strings.Replace(q, "'", `\'`, -1) + "'"
+}
+
+// usesIota reports whether the values of a constant declaration use iota
+func usesIota(vs *ast.ValueSpec) bool {
+	found := false
+	ast.Inspect(vs, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,305 @@
+package parse
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// jsonField is a field of a struct as encoding/json sees it
+type jsonField struct {
+	// name is the key the field is written under
+	name string
+
+	// tagged is set when name comes from the json tag
+	tagged bool
+
+	// hasTag is set when the field has a json tag, even one of only options such as ",omitempty"
+	hasTag bool
+
+	// index is the path of field indexes through embedded structs
+	index []int
+
+	// v is the Go field
+	v *types.Var
+
+	// tag is the whole struct tag of the field
+	tag string
+}
+
+// jsonFields returns the fields encoding/json writes for st, ordered as they are declared.
+// It follows typeFields in encoding/json: unexported fields are dropped, untagged fields use
+// their Go name, embedded structs without a name are promoted, and of the fields sharing a
+// name only the shallowest survives, preferring a tagged one. Fields left tied cancel out.
+func jsonFields(st *types.Struct) []jsonField {
+	type scan struct {
+		typ   types.Type
+		index []int
+	}
+
+	current := []scan{}
+	next := []scan{{typ: st}}
+
+	// count and nextCount are how many times a struct is embedded at this depth and the next
+	count := map[types.Type]int{}
+	nextCount := map[types.Type]int{}
+	visited := map[types.Type]bool{}
+
+	fields := []jsonField{}
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[types.Type]int{}
+
+		for _, s := range current {
+			if visited[s.typ] {
+				continue
+			}
+			visited[s.typ] = true
+
+			st, ok := s.typ.Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			for i := 0; i < st.NumFields(); i++ {
+				sf := st.Field(i)
+				ft := deref(sf.Type())
+				if sf.Anonymous() {
+					if !sf.Exported() && !isStruct(ft) {
+						continue
+					}
+				} else if !sf.Exported() {
+					continue
+				}
+
+				tag, hasTag := reflect.StructTag(st.Tag(i)).Lookup("json")
+				if tag == "-" {
+					continue
+				}
+				name, _ := splitTag(tag)
+				if !validTagName(name) {
+					name = ""
+				}
+				index := make([]int, len(s.index)+1)
+				copy(index, s.index)
+				index[len(s.index)] = i
+
+				// Record anything that isn't an untagged embedded struct
+				if name != "" || !sf.Anonymous() || !isStruct(ft) {
+					f := jsonField{name: name, tagged: name != "", hasTag: hasTag, index: index, v: sf, tag: st.Tag(i)}
+					if f.name == "" {
+						f.name = sf.Name()
+					}
+					fields = append(fields, f)
+					if count[s.typ] > 1 {
+						// The struct was embedded twice at this depth, so the field
+						// conflicts with itself and is dropped below
+						fields = append(fields, f)
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, scan{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return lessIndex(x.index, y.index)
+	})
+
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fields[i].name {
+				break
+			}
+		}
+		group := fields[i : i+advance]
+		if len(group) > 1 && len(group[0].index) == len(group[1].index) && group[0].tagged == group[1].tagged {
+			continue
+		}
+		out = append(out, group[0])
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return lessIndex(out[i].index, out[j].index)
+	})
+	return out
+}
+
+// jsonStruct turns the fields encoding/json writes for st into fields to be written.
+// Embedded structs are already flattened into their promoted fields. Fields without a json
+// tag are only kept when all is set, apart from embedded types that are not structs. A tag of
+// only options, as in ",omitempty", keeps the field under its Go name.
+func (p *Parse) jsonStruct(st *types.Struct, all bool) []field {
+	defer func(at token.Pos) { p.at = at }(p.at)
+
+	out := []field{}
+	for _, f := range jsonFields(st) {
+		if !f.hasTag && !f.v.Anonymous() && !all {
+			continue
+		}
+		p.at = f.v.Pos()
+		if bad := p.unserializable(f.v.Type()); bad != nil && parseFlowTag(getTag("flow", f.tag)).typ == "" {
+			if p.rejected("field "+f.v.Name(), bad) {
+				continue
+			}
+		}
+		newField := p.newField(f.v.Name(), f.tag, f.v.Type(), p.commentOf(f.v))
+		newField.tags.json = f.name
+		out = append(out, newField)
+	}
+	return out
+}
+
+// hoist moves the anonymous structs within fields into types of their own, named after
+// the type and field holding them. Structs within those are hoisted in turn.
+func (p *Parse) hoist(parent string, fields []field) {
+	for i := range fields {
+		fields[i].typ = p.hoistType(parent+"_"+fields[i].name, fields[i].typ, p.typeParams[parent])
+	}
+}
+
+// hoistType replaces an anonymous struct within t with a reference to name. The struct
+// takes on the type parameters of params it uses, which the reference passes on.
+func (p *Parse) hoistType(name string, t *typeExpr, params []typeParam) *typeExpr {
+	switch t.kind {
+	case KindObject:
+		// There is nothing to name in an empty struct
+		if len(t.fields) == 0 {
+			return t
+		}
+		// A type may already be declared with the name, so another is taken
+		name = p.take(name)
+		ref := &typeExpr{kind: KindNamed, name: name}
+		used := []typeParam{}
+		for _, tp := range params {
+			if usesParam(t, tp.name) {
+				used = append(used, tp)
+				ref.args = append(ref.args, &typeExpr{kind: KindParam, name: tp.name})
+			}
+		}
+		if len(used) > 0 {
+			p.typeParams[name] = used
+		}
+		p.hoist(name, t.fields)
+		p.mappings[name] = t.fields
+		return ref
+	case KindNullable, KindArray, KindTuple, KindMap:
+		hoisted := *t
+		hoisted.elem = p.hoistType(name, t.elem, params)
+		return &hoisted
+	}
+	return t
+}
+
+// usesParam reports whether the type parameter name appears anywhere within t
+func usesParam(t *typeExpr, name string) bool {
+	if t == nil {
+		return false
+	}
+	if t.kind == KindParam && t.name == name {
+		return true
+	}
+	if usesParam(t.elem, name) || usesParam(t.key, name) {
+		return true
+	}
+	for _, list := range [][]*typeExpr{t.args, t.members} {
+		for _, a := range list {
+			if usesParam(a, name) {
+				return true
+			}
+		}
+	}
+	for _, f := range t.fields {
+		if usesParam(f.typ, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentOf returns the comment beside the declaration of a struct field.
+// The fields of a package are indexed the first time one of them is asked for.
+func (p *Parse) commentOf(v *types.Var) string {
+	if v.Pkg() == nil {
+		return ""
+	}
+	pkgPath := v.Pkg().Path()
+	if !p.scanned[pkgPath] {
+		p.scanned[pkgPath] = true
+		src := p.sourceOf(v.Pkg())
+		for _, f := range src.files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				st, ok := n.(*ast.StructType)
+				if !ok {
+					return true
+				}
+				for _, field := range st.Fields.List {
+					if field.Comment == nil {
+						continue
+					}
+					for _, id := range field.Names {
+						if fv, ok := src.info.Defs[id].(*types.Var); ok {
+							p.fieldComments[fv] = field.Comment.Text()
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return p.fieldComments[v]
+}
+
+// isStruct reports whether t is a struct, once named types are looked through
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+// lessIndex orders field index paths by the order the fields are declared in
+func lessIndex(a, b []int) bool {
+	for i := range a {
+		if i >= len(b) {
+			return false
+		}
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// validTagName reports whether encoding/json accepts name from a json tag
+func validTagName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but otherwise any punctuation chars are allowed
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package parse
+
+import (
+	"go/types"
+)
+
+// typeParam is a type parameter of a generic type
+type typeParam struct {
+	name string
+
+	// bound is the type the parameter is limited to, or nil when it is anything
+	bound *typeExpr
+}
+
+// typeParamsOf returns the type parameters of a generic type or alias. Constraints made of
+// types, such as ~string | ~int, become bounds. Constraints with methods can't be written,
+// so the parameter is left unbounded and diagnosed.
+func (p *Parse) typeParamsOf(obj *types.TypeName) []typeParam {
+	var list *types.TypeParamList
+	switch t := obj.Type().(type) {
+	case *types.Named:
+		list = t.TypeParams()
+	case *types.Alias:
+		list = t.TypeParams()
+	}
+	params := []typeParam{}
+	for i := 0; i < list.Len(); i++ {
+		tp := list.At(i)
+		param := typeParam{name: tp.Obj().Name()}
+		if iface, ok := tp.Constraint().Underlying().(*types.Interface); ok {
+			if iface.NumMethods() > 0 {
+				p.diagnose(obj, "the constraint %s of %s has methods, which can't be written, so %s is unbounded",
+					types.TypeString(tp.Constraint(), types.RelativeTo(obj.Pkg())), param.name, param.name)
+			} else {
+				param.bound = p.bound(iface)
+			}
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// bound returns the union of the types a constraint allows, or nil if it allows any type
+func (p *Parse) bound(iface *types.Interface) *typeExpr {
+	union := &typeExpr{kind: KindUnion}
+	seen := make(map[string]bool)
+	for _, t := range terms(iface) {
+		typ := p.resolve(t)
+		if s := flowType(typ); !seen[s] {
+			seen[s] = true
+			union.members = append(union.members, typ)
+		}
+	}
+	switch len(union.members) {
+	case 0:
+		return nil
+	case 1:
+		return union.members[0]
+	}
+	return union
+}
+
+// terms returns the types of the unions within a constraint, including those of the
+// constraints it embeds
+func terms(iface *types.Interface) []types.Type {
+	out := []types.Type{}
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		switch e := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < e.Len(); j++ {
+				out = append(out, e.Term(j).Type())
+			}
+		default:
+			if inner, ok := e.Underlying().(*types.Interface); ok {
+				out = append(out, terms(inner)...)
+			} else {
+				out = append(out, e)
+			}
+		}
+	}
+	return out
+}
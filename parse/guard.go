@@ -0,0 +1,140 @@
+package parse
+
+import (
+	"fmt"
+	"go/constant"
+	"strings"
+)
+
+// guardFunc writes a function checking at runtime that a value is of the type declared, as in
+// isPerson(x). Type parameters and types given as Flow text that isn't a primitive or another
+// declaration can't be checked, so any value passes for them.
+func (w *jsWriter) guardFunc(d Decl) {
+	typ := d.Type
+	var conds []string
+	if d.Struct && typ.Kind == KindObject {
+		conds = w.objectGuard(typ.Fields, "x", d.Strict(), 0)
+	} else {
+		conds = []string{w.guard(typ, "x", 0)}
+	}
+	w.WriteString(fmt.Sprintf("export function is%s(x: mixed): boolean %%checks {\n", d.Name))
+	w.WriteString(fmt.Sprintf("\treturn (\n\t\t%s\n\t)\n}\n\n", strings.Join(conds, " &&\n\t\t")))
+}
+
+// objectGuard checks an object has each of its fields, and only those when strict
+func (w *jsWriter) objectGuard(fields []Field, v string, strict bool, depth int) []string {
+	conds := []string{fmt.Sprintf("typeof %s === 'object' && %s !== null && !Array.isArray(%s)", v, v, v)}
+	names := []string{}
+	for _, f := range fields {
+		names = append(names, literal(constant.MakeString(f.Name)))
+		access := v + "." + f.Name
+		if name := propName(f.Name); name != f.Name {
+			access = v + "[" + name + "]"
+		}
+		cond := w.guard(f.Type, access, depth)
+		if cond == "true" {
+			// Any value will do, so long as it is there
+			cond = fmt.Sprintf("%s !== undefined", access)
+		}
+		if f.Optional {
+			cond = fmt.Sprintf("(%s === undefined || %s)", access, cond)
+		}
+		conds = append(conds, cond)
+	}
+	if strict {
+		k := fmt.Sprintf("k%d", depth)
+		conds = append(conds, fmt.Sprintf("Object.keys(%s).every((%s) => [%s].includes(%s))", v, k, strings.Join(names, ", "), k))
+	}
+	return conds
+}
+
+// guard writes an expression that is true when v is of type t. Each level of nesting
+// names the elements it checks a level deeper, so they don't shadow each other.
+func (w *jsWriter) guard(t *Type, v string, depth int) string {
+	if t == nil {
+		return "true"
+	}
+	switch t.Kind {
+	case KindPrimitive:
+		switch t.Name {
+		case "string", "boolean":
+			return fmt.Sprintf("typeof %s === '%s'", v, t.Name)
+		case "number":
+			if t.Integer {
+				return fmt.Sprintf("Number.isInteger(%s)", v)
+			}
+			return fmt.Sprintf("typeof %s === 'number'", v)
+		default:
+			return "true"
+		}
+	case KindNamed:
+		if !w.decls[t.Name] {
+			return "true"
+		}
+		return fmt.Sprintf("is%s(%s)", t.Name, v)
+	case KindNullable:
+		elem := w.guard(t.Elem, v, depth)
+		if elem == "true" {
+			return elem
+		}
+		// ?T is undefined as well as null, but T | null is only null
+		if w.opts.NullUnions {
+			return fmt.Sprintf("(%s === null || %s)", v, elem)
+		}
+		return fmt.Sprintf("(%s == null || %s)", v, elem)
+	case KindArray, KindTuple:
+		e := fmt.Sprintf("e%d", depth)
+		cond := fmt.Sprintf("Array.isArray(%s)", v)
+		if t.Kind == KindTuple {
+			cond += fmt.Sprintf(" && %s.length === %d", v, t.Length)
+		}
+		elem := w.guard(t.Elem, e, depth+1)
+		if elem == "true" && t.Kind == KindArray {
+			return cond
+		}
+		if elem != "true" {
+			cond += fmt.Sprintf(" && %s.every((%s) => %s)", v, e, elem)
+		}
+		return "(" + cond + ")"
+	case KindMap:
+		k := fmt.Sprintf("k%d", depth)
+		conds := []string{}
+		// Keys are always strings, so only named keys that are strings can be checked
+		if t.Key != nil && t.Key.Kind == KindNamed && !w.opts.NumberKeys {
+			if key := w.guard(t.Key, k, depth+1); key != "true" {
+				conds = append(conds, key)
+			}
+		}
+		if elem := w.guard(t.Elem, v+"["+k+"]", depth+1); elem != "true" {
+			conds = append(conds, elem)
+		}
+		cond := fmt.Sprintf("typeof %s === 'object' && %s !== null && !Array.isArray(%s)", v, v, v)
+		if len(conds) > 0 {
+			cond += fmt.Sprintf(" && Object.keys(%s).every((%s) => %s)", v, k, strings.Join(conds, " && "))
+		}
+		return "(" + cond + ")"
+	case KindObject:
+		return "(" + strings.Join(w.objectGuard(t.Fields, v, false, depth), " && ") + ")"
+	case KindLiteral:
+		return fmt.Sprintf("%s === %s", v, t.Name)
+	case KindUnion:
+		members := make([]string, len(t.Members))
+		for i, m := range t.Members {
+			if members[i] = w.guard(m, v, depth); members[i] == "true" {
+				return "true"
+			}
+		}
+		return "(" + strings.Join(members, " || ") + ")"
+	case KindRaw:
+		// Only primitives and other declarations are understood
+		switch name := strings.TrimSpace(t.Name); {
+		case name == "string" || name == "number" || name == "boolean":
+			return w.guard(&Type{Kind: KindPrimitive, Name: name}, v, depth)
+		case w.decls[name]:
+			return fmt.Sprintf("is%s(%s)", name, v)
+		}
+		return "true"
+	default:
+		return "true"
+	}
+}
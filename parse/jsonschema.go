@@ -0,0 +1,332 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect JSONSchemaEmitter writes
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchemaEmitter writes a JSON Schema document with every type under $defs.
+// Structs are object schemas, requiring each field that is neither omitempty nor nullable,
+// and @strict types allow no other properties. Type parameters are their bound, since
+// JSON Schema has no generics, so an instantiated type refers to the generic one.
+type JSONSchemaEmitter struct{}
+
+// Extension is the extension of JSON Schema files
+func (JSONSchemaEmitter) Extension() string {
+	return ".schema.json"
+}
+
+// Emit writes doc as a JSON Schema document
+func (JSONSchemaEmitter) Emit(w io.Writer, doc *Document) error {
+	defs, err := newSchemaWriter(doc, "#/$defs/").schemas(doc)
+	if err != nil {
+		return err
+	}
+	root := schema{}.with("$schema", JSONSchemaDraft).with("$defs", defs)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(root)
+}
+
+// schemaWriter turns declarations into JSON Schemas, which OpenAPI shares
+type schemaWriter struct {
+	opts Options
+
+	// decls are the names of every declaration, which are referred to under refs
+	decls map[string]bool
+
+	// refs is where the schemas of declarations are, as in #/$defs/
+	refs string
+
+	// params are the bounds of the type parameters of the declaration being written
+	params map[string]*Type
+}
+
+func newSchemaWriter(doc *Document, refs string) *schemaWriter {
+	w := &schemaWriter{opts: doc.Options, decls: make(map[string]bool), refs: refs}
+	for _, d := range doc.Decls {
+		w.decls[d.Name] = true
+	}
+	return w
+}
+
+// schemas writes the schema of every declaration by name
+func (w *schemaWriter) schemas(doc *Document) (schema, error) {
+	out := schema{}
+	for _, d := range doc.Decls {
+		s, err := w.decl(d)
+		if err != nil {
+			return nil, err
+		}
+		out = out.with(d.Name, s)
+	}
+	return out, nil
+}
+
+// decl writes the schema of a declaration
+func (w *schemaWriter) decl(d Decl) (schema, error) {
+	// A @jsonschema directive replaces whatever the type would be written as
+	if raw, ok := d.Directives["jsonschema"]; ok {
+		var obj rawObject
+		if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+			return nil, fmt.Errorf("@jsonschema of %s is not a JSON object: %v", d.Name, err)
+		}
+		return schema{}.with("description", description(d.Comment)).merge(schema(obj)), nil
+	}
+
+	w.params = make(map[string]*Type)
+	for _, tp := range d.TypeParams {
+		w.params[tp.Name] = tp.Bound
+	}
+
+	var s schema
+	if d.Struct && d.Type.Kind == KindObject {
+		s = w.object(d.Type.Fields)
+		if d.Strict() {
+			s = s.with("additionalProperties", false)
+		}
+	} else {
+		s = w.typeOf(d.Type)
+	}
+	return schema{}.with("description", description(d.Comment)).merge(s), nil
+}
+
+// object writes the schema of the fields of a struct
+func (w *schemaWriter) object(fields []Field) schema {
+	props := schema{}
+	required := []string{}
+	for _, f := range fields {
+		props = props.with(f.Name, schema{}.with("description", description(f.Comment)).merge(w.typeOf(f.Type)))
+		// encoding/json always writes a field, but a nil pointer is only null
+		if !f.Optional && (f.Type == nil || f.Type.Kind != KindNullable) {
+			required = append(required, f.Name)
+		}
+	}
+	s := schema{}.with("type", "object").with("properties", props)
+	if len(required) > 0 {
+		s = s.with("required", required)
+	}
+	return s
+}
+
+// typeOf writes the schema of a type
+func (w *schemaWriter) typeOf(t *Type) schema {
+	if t == nil {
+		return schema{}
+	}
+	switch t.Kind {
+	case KindPrimitive:
+		return primitiveSchema(t)
+	case KindNamed:
+		// Named types are declared, unless they are written by an import
+		if !w.decls[t.Name] {
+			return schema{}
+		}
+		return schema{}.with("$ref", w.refs+t.Name)
+	case KindParam:
+		return w.typeOf(w.params[t.Name])
+	case KindNullable:
+		elem := w.typeOf(t.Elem)
+		if typ, ok := elem.get("type").(string); ok && len(elem) == 1 {
+			return schema{}.with("type", []string{typ, "null"})
+		}
+		return schema{}.with("anyOf", []schema{elem, schema{}.with("type", "null")})
+	case KindArray:
+		return schema{}.with("type", "array").with("items", w.typeOf(t.Elem))
+	case KindTuple:
+		items := make([]schema, t.Length)
+		for i := range items {
+			items[i] = w.typeOf(t.Elem)
+		}
+		return schema{}.with("type", "array").with("prefixItems", items).with("items", false)
+	case KindMap:
+		// Keys are always strings in JSON, so only string types and integers can say what they are.
+		// Named keys are only strings when NumberKeys leaves integer keys out.
+		s := schema{}.with("type", "object")
+		switch {
+		case t.Key == nil:
+		case t.Key.Kind == KindNamed && !w.opts.NumberKeys:
+			s = s.with("propertyNames", w.typeOf(t.Key))
+		case t.Key.Kind == KindPrimitive && t.Key.Name == "number":
+			s = s.with("propertyNames", schema{}.with("pattern", "^-?[0-9]+$"))
+		}
+		return s.with("additionalProperties", w.typeOf(t.Elem))
+	case KindObject:
+		return w.object(t.Fields)
+	case KindLiteral:
+		return schema{}.with("const", literalValue(t.Name))
+	case KindUnion:
+		values := []interface{}{}
+		members := []schema{}
+		for _, m := range t.Members {
+			if m.Kind == KindLiteral {
+				values = append(values, literalValue(m.Name))
+			}
+			members = append(members, schema{}.with("description", description(m.Comment)).merge(w.typeOf(m)))
+		}
+		// Enums are only ever one of their constants
+		if len(values) == len(t.Members) {
+			return schema{}.with("enum", values)
+		}
+		return schema{}.with("anyOf", members)
+	case KindRaw:
+		return w.raw(t.Name)
+	default:
+		return schema{}
+	}
+}
+
+// raw writes the schema of a type overridden by its Flow text, which is only understood
+// when it is a primitive or another declaration. Anything else may be any value.
+func (w *schemaWriter) raw(text string) schema {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "?") {
+		return w.typeOf(&Type{Kind: KindNullable, Elem: &Type{Kind: KindRaw, Name: text[1:]}})
+	}
+	switch text {
+	case "string", "number", "boolean":
+		return primitiveSchema(&Type{Kind: KindPrimitive, Name: text})
+	}
+	if w.decls[text] {
+		return schema{}.with("$ref", w.refs+text)
+	}
+	return schema{}
+}
+
+// primitiveSchema writes the schema of a primitive, where mixed and any are anything
+func primitiveSchema(t *Type) schema {
+	switch t.Name {
+	case "string", "boolean":
+		return schema{}.with("type", t.Name)
+	case "number":
+		if t.Integer {
+			return schema{}.with("type", "integer")
+		}
+		return schema{}.with("type", "number")
+	default:
+		return schema{}
+	}
+}
+
+// literalValue turns a literal written for Flow back into its JSON value
+func literalValue(lit string) interface{} {
+	if strings.HasPrefix(lit, "'") && strings.HasSuffix(lit, "'") && len(lit) > 1 {
+		q := strings.Replace(lit[1:len(lit)-1], `\'`, "'", -1)
+		q = strings.Replace(q, `"`, `\"`, -1)
+		if s, err := strconv.Unquote(`"` + q + `"`); err == nil {
+			return s
+		}
+	}
+	if json.Valid([]byte(lit)) {
+		return json.RawMessage(lit)
+	}
+	return lit
+}
+
+// description is a doc comment without its directives
+func description(comment string) string {
+	lines := []string{}
+	for _, line := range strings.Split(comment, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "@") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// schema is a JSON object that keeps its keys in the order they are added
+type schema []schemaKey
+
+type schemaKey struct {
+	name  string
+	value interface{}
+}
+
+// with adds a key to the schema, leaving out empty descriptions
+func (s schema) with(name string, value interface{}) schema {
+	if str, ok := value.(string); ok && str == "" && name == "description" {
+		return s
+	}
+	return append(s, schemaKey{name, value})
+}
+
+// get returns the value of a key, or nil
+func (s schema) get(name string) interface{} {
+	for _, k := range s {
+		if k.name == name {
+			return k.value
+		}
+	}
+	return nil
+}
+
+// merge adds the keys of other after those of s
+func (s schema) merge(other schema) schema {
+	return append(s, other...)
+}
+
+// MarshalJSON writes the keys in order
+func (s schema) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, k := range s {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, err := marshalJSON(k.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(k.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// rawObject reads a JSON object, keeping its keys in order, such as that of a @jsonschema directive
+type rawObject []schemaKey
+
+func (r *rawObject) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		*r = append(*r, schemaKey{tok.(string), value})
+	}
+	return nil
+}
+
+// marshalJSON is json.Marshal without escaping HTML, since < and > are common in comments
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,243 @@
+package parse
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"path/filepath"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/tools/go/packages"
+)
+
+// loadMode type-checks the packages of p.Files from source. What they import is read from
+// export data, and its files are only parsed once the declaration of a type is needed.
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports |
+	packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo
+
+// loadPackages loads packages, which tests replace to load testdata once
+var loadPackages = packages.Load
+
+// file is a parsed Go file and the type information of its package
+type file struct {
+	syntax *ast.File
+	info   *types.Info
+}
+
+// load type-checks every package that holds one of p.Files and returns those files
+// in the order of p.Files. Type errors are logged rather than returned so a partially
+// broken package still generates.
+func (p *Parse) load() ([]file, error) {
+	paths := []string{}
+	dirs := []string{}
+	for _, f := range p.Files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, abs)
+		dirs = append(dirs, filepath.Dir(abs))
+	}
+	dirs = removeDuplicates(dirs)
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+
+	p.dir = dirs[0]
+	cfg := &packages.Config{Mode: loadMode, Dir: p.dir}
+	pkgs, err := loadPackages(cfg, dirs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) > 0 {
+		p.fset = pkgs[0].Fset
+	}
+
+	byPath := make(map[string]file)
+	for _, pkg := range pkgs {
+		for _, err := range pkg.Errors {
+			log.WithField("package", pkg.PkgPath).Warn(err)
+		}
+		for _, f := range pkg.Syntax {
+			byPath[pkg.Fset.File(f.Pos()).Name()] = file{syntax: f, info: pkg.TypesInfo}
+		}
+		p.loaded[pkg.PkgPath] = pkg
+	}
+
+	out := []file{}
+	for _, path := range paths {
+		if f, ok := byPath[path]; ok {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// declOf finds where obj is declared within its package
+func (p *Parse) declOf(obj *types.TypeName) (typeDecl, *types.Info, bool) {
+	src := p.sourceOf(obj.Pkg())
+	for _, f := range src.files {
+		for _, d := range typeDecls(f) {
+			if src.info.Defs[d.spec.Name] == obj {
+				return d, src.info, true
+			}
+		}
+	}
+	return typeDecl{}, nil, false
+}
+
+// source is the syntax of a package and the objects its identifiers define
+type source struct {
+	files []*ast.File
+	info  *types.Info
+}
+
+// sourceOf returns the syntax of pkg. The packages of p.Files already have theirs, while the
+// files of a package imported from export data are parsed the first time it is asked for.
+// Only its top level types and constants, and the fields of its structs, are defined, which
+// is all declOf, constsOf and commentOf look up.
+func (p *Parse) sourceOf(pkg *types.Package) source {
+	if loaded, ok := p.loaded[pkg.Path()]; ok && loaded.TypesInfo != nil {
+		return source{files: loaded.Syntax, info: loaded.TypesInfo}
+	}
+	if src, ok := p.sources[pkg.Path()]; ok {
+		return src
+	}
+
+	src := source{info: &types.Info{Defs: make(map[*ast.Ident]types.Object), Types: make(map[ast.Expr]types.TypeAndValue)}}
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedFiles, Dir: p.dir}
+	pkgs, err := loadPackages(cfg, pkg.Path())
+	if err != nil {
+		log.WithError(err).WithField("package", pkg.Path()).Warn("error finding the files of package")
+	}
+	for _, loaded := range pkgs {
+		for _, path := range loaded.GoFiles {
+			f, err := parser.ParseFile(p.fset, path, nil, parser.ParseComments)
+			if err != nil {
+				log.WithError(err).WithField("file", path).Warn("error parsing file")
+				continue
+			}
+			src.files = append(src.files, f)
+			define(pkg, f, src.info)
+		}
+	}
+	p.sources[pkg.Path()] = src
+	return src
+}
+
+// define matches the declarations of f to the objects of pkg by name, recording what
+// each type is declared as
+func define(pkg *types.Package, f *ast.File, info *types.Info) {
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				obj, ok := pkg.Scope().Lookup(s.Name.Name).(*types.TypeName)
+				if !ok {
+					continue
+				}
+				info.Defs[s.Name] = obj
+				rhs := rhsOf(obj, f, s.Type)
+				info.Types[s.Type] = types.TypeAndValue{Type: rhs}
+				defineFields(s.Type, rhs, info)
+			case *ast.ValueSpec:
+				for _, id := range s.Names {
+					if c, ok := pkg.Scope().Lookup(id.Name).(*types.Const); ok {
+						info.Defs[id] = c
+					}
+				}
+			}
+		}
+	}
+}
+
+// rhsOf returns the type obj is declared as by expr. Export data only has the underlying type
+// of a defined type, so a type defined as another named type looks that type up.
+func rhsOf(obj *types.TypeName, f *ast.File, expr ast.Expr) types.Type {
+	if alias, ok := obj.Type().(*types.Alias); ok {
+		return alias.Rhs()
+	}
+	var named types.Object
+	switch x := expr.(type) {
+	case *ast.Ident:
+		named = obj.Pkg().Scope().Lookup(x.Name)
+	case *ast.SelectorExpr:
+		if id, ok := x.X.(*ast.Ident); ok {
+			if imported := importOf(obj.Pkg(), f, id.Name); imported != nil {
+				named = imported.Scope().Lookup(x.Sel.Name)
+			}
+		}
+	}
+	if tn, ok := named.(*types.TypeName); ok {
+		return tn.Type()
+	}
+	return obj.Type().Underlying()
+}
+
+// importOf returns the package f imports as name, or nil
+func importOf(pkg *types.Package, f *ast.File, name string) *types.Package {
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		for _, imported := range pkg.Imports() {
+			if imported.Path() != path {
+				continue
+			}
+			if (spec.Name != nil && spec.Name.Name == name) || (spec.Name == nil && imported.Name() == name) {
+				return imported
+			}
+		}
+	}
+	return nil
+}
+
+// defineFields matches the fields of the structs within expr to those of t, in order
+func defineFields(expr ast.Expr, t types.Type, info *types.Info) {
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		defineFields(x.X, t, info)
+	case *ast.StarExpr:
+		if ptr, ok := t.(*types.Pointer); ok {
+			defineFields(x.X, ptr.Elem(), info)
+		}
+	case *ast.ArrayType:
+		switch y := t.(type) {
+		case *types.Slice:
+			defineFields(x.Elt, y.Elem(), info)
+		case *types.Array:
+			defineFields(x.Elt, y.Elem(), info)
+		}
+	case *ast.MapType:
+		if m, ok := t.(*types.Map); ok {
+			defineFields(x.Key, m.Key(), info)
+			defineFields(x.Value, m.Elem(), info)
+		}
+	case *ast.StructType:
+		st, ok := t.(*types.Struct)
+		if !ok {
+			return
+		}
+		i := 0
+		for _, field := range x.Fields.List {
+			if i >= st.NumFields() {
+				return
+			}
+			for k, id := range field.Names {
+				if i+k < st.NumFields() {
+					info.Defs[id] = st.Field(i + k)
+				}
+			}
+			defineFields(field.Type, st.Field(i).Type(), info)
+			// An embedded field has no name, but is a field all the same
+			i += max(len(field.Names), 1)
+		}
+	}
+}
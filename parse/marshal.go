@@ -0,0 +1,100 @@
+package parse
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// marshaled returns what a type with its own MarshalJSON or MarshalText method is written as,
+// or nil if it has neither. Like encoding/json, MarshalJSON wins over MarshalText, and
+// methods on the pointer count as well as methods on the value. Text is always a string,
+// but what MarshalJSON writes is unknown, so it is written as mixed and diagnosed. Types with
+// a @flowtype directive or a registry entry are written as those say before this is asked,
+// and so are types whose marshaler is promoted from an embedded type that has either.
+func (p *Parse) marshaled(obj *types.TypeName) *typeExpr {
+	for _, name := range []string{"MarshalJSON", "MarshalText"} {
+		if !hasMarshaler(types.NewPointer(obj.Type()), name) {
+			continue
+		}
+		if embedded := promotedFrom(obj.Type(), name); embedded != nil {
+			if typ := p.flowTypeOf(embedded); typ != "" {
+				return &typeExpr{kind: KindRaw, name: typ}
+			}
+			if typ, ok := p.wellKnown(embedded); ok {
+				return typ
+			}
+		}
+		break
+	}
+
+	switch {
+	case hasMarshaler(types.NewPointer(obj.Type()), "MarshalJSON"):
+		p.diagnose(obj, "implements json.Marshaler, so its JSON is unknown and it is written as mixed; override it with a @flowtype directive, a -types registry entry, or flow:\".type\" on fields using it")
+		return primitive("mixed")
+	case hasMarshaler(types.NewPointer(obj.Type()), "MarshalText"):
+		return primitive("string")
+	}
+	return nil
+}
+
+// promotedFrom returns the embedded type the method name of t is promoted from, or nil
+// when t declares it itself
+func promotedFrom(t types.Type, name string) *types.TypeName {
+	sel := types.NewMethodSet(types.NewPointer(t)).Lookup(nil, name)
+	if sel == nil || len(sel.Index()) < 2 {
+		return nil
+	}
+	// Every index but the last is an embedded field, each within the last
+	for _, i := range sel.Index()[:len(sel.Index())-1] {
+		st, ok := deref(t).Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		t = st.Field(i).Type()
+	}
+	if named, ok := deref(t).(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+// hasMarshaler reports whether t has a method name of the form func() ([]byte, error)
+func hasMarshaler(t types.Type, name string) bool {
+	sel := types.NewMethodSet(t).Lookup(nil, name)
+	if sel == nil {
+		return false
+	}
+	sig, ok := sel.Type().(*types.Signature)
+	if !ok || sig.Params().Len() != 0 || sig.Results().Len() != 2 {
+		return false
+	}
+	slice, ok := sig.Results().At(0).Type().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := slice.Elem().(*types.Basic)
+	return ok && b.Kind() == types.Uint8 && types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// mapKey resolves the key of a map the way encoding/json writes it. String keys are written
+// as they are, while TextMarshaler and integer keys become strings, unless NumberKeys keeps
+// integers as numbers. encoding/json refuses any other key.
+func (p *Parse) mapKey(t types.Type) *typeExpr {
+	if _, ok := t.(*types.TypeParam); ok {
+		return p.resolve(t)
+	}
+	b, _ := t.Underlying().(*types.Basic)
+	switch {
+	case b != nil && b.Info()&types.IsString != 0:
+		return p.resolve(t)
+	case hasMarshaler(t, "MarshalText"):
+		return primitive("string")
+	case b != nil && b.Info()&types.IsInteger != 0:
+		if p.Options.NumberKeys {
+			return p.resolve(t)
+		}
+		return primitive("string")
+	}
+	p.diagnoseAt(token.NoPos, types.TypeString(t, nil), "can't be a map key, since encoding/json only writes string, integer and encoding.TextMarshaler keys")
+	return primitive("string")
+}
@@ -0,0 +1,239 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// OpenAPIVersion is the OpenAPI version OpenAPIEmitter writes
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIEmitter writes an OpenAPI document of only components.schemas, referring to each
+// other by $ref. OpenAPI 3.1 schemas are JSON Schemas, so they are written as
+// JSONSchemaEmitter writes them, with nullable types allowing null.
+type OpenAPIEmitter struct {
+	// YAML writes YAML rather than JSON
+	YAML bool
+
+	// Title and Version are the info of the document, "models" and "0.0.0" when unset
+	Title, Version string
+}
+
+// Extension is the extension of OpenAPI files, .yaml or .json. A .yml file is YAML as well.
+func (e OpenAPIEmitter) Extension() string {
+	if e.YAML {
+		return ".yaml"
+	}
+	return ".json"
+}
+
+// Emit writes doc as an OpenAPI document
+func (e OpenAPIEmitter) Emit(w io.Writer, doc *Document) error {
+	schemas, err := newSchemaWriter(doc, "#/components/schemas/").schemas(doc)
+	if err != nil {
+		return err
+	}
+
+	title, version := e.Title, e.Version
+	if title == "" {
+		title = "models"
+	}
+	if version == "" {
+		version = "0.0.0"
+	}
+	root := schema{}.
+		with("openapi", OpenAPIVersion).
+		with("info", schema{}.with("title", title).with("version", version)).
+		with("components", schema{}.with("schemas", schemas))
+
+	if !e.YAML {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		return enc.Encode(root)
+	}
+
+	y := &yamlWriter{}
+	y.WriteString("# DO NOT EDIT -- automatically generated by goflow\n\n")
+	node, err := toYAML(root)
+	if err != nil {
+		return err
+	}
+	y.mapping(node.(schema), 0)
+	_, err = io.WriteString(w, y.String())
+	return err
+}
+
+// yamlScalar is a value already written as YAML
+type yamlScalar string
+
+// toYAML turns a schema into schemas, lists and scalars, reading any raw JSON within it
+func toYAML(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case schema:
+		out := schema{}
+		for _, k := range x {
+			value, err := toYAML(k.value)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, schemaKey{k.name, value})
+		}
+		return out, nil
+	case []schema:
+		out := []interface{}{}
+		for _, item := range x {
+			value, err := toYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case []string:
+		out := []interface{}{}
+		for _, item := range x {
+			out = append(out, yamlString(item))
+		}
+		return out, nil
+	case []interface{}:
+		out := []interface{}{}
+		for _, item := range x {
+			value, err := toYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case string:
+		return yamlString(x), nil
+	case json.RawMessage:
+		return rawYAML(x)
+	default:
+		// Booleans and numbers are written the same in JSON and YAML
+		b, err := json.Marshal(x)
+		if err != nil {
+			return nil, err
+		}
+		return rawYAML(b)
+	}
+}
+
+// rawYAML reads raw JSON, keeping the keys of objects in order
+func rawYAML(raw []byte) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case bytes.HasPrefix(raw, []byte("{")):
+		var obj rawObject
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		return toYAML(schema(obj))
+	case bytes.HasPrefix(raw, []byte("[")):
+		var list []json.RawMessage
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		out := []interface{}{}
+		for _, item := range list {
+			value, err := rawYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case bytes.HasPrefix(raw, []byte(`"`)):
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return yamlString(s), nil
+	default:
+		return yamlScalar(raw), nil
+	}
+}
+
+// plainYAML are the strings that can be written without quotes
+var plainYAML = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$./ ,'()-]*$`)
+
+// yamlString writes a string, quoted when YAML would read it as something else
+func yamlString(s string) yamlScalar {
+	switch strings.ToLower(s) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null":
+	default:
+		if plainYAML.MatchString(s) && !strings.HasSuffix(s, " ") {
+			return yamlScalar(s)
+		}
+	}
+	// A JSON string is a double quoted YAML string
+	b, _ := marshalJSON(s)
+	return yamlScalar(b)
+}
+
+// yamlWriter writes block style YAML, indenting two spaces a level
+type yamlWriter struct {
+	bytes.Buffer
+}
+
+// mapping writes the keys of a schema at indent
+func (y *yamlWriter) mapping(s schema, indent int) {
+	for _, k := range s {
+		y.WriteString(fmt.Sprintf("%s%s:", strings.Repeat(" ", indent), yamlString(k.name)))
+		y.value(k.value, indent)
+	}
+}
+
+// list writes the items of a list at indent
+func (y *yamlWriter) list(items []interface{}, indent int) {
+	dash := strings.Repeat(" ", indent) + "- "
+	for _, item := range items {
+		var inner yamlWriter
+		switch x := item.(type) {
+		case schema:
+			if len(x) == 0 {
+				y.WriteString(dash + "{}\n")
+				continue
+			}
+			inner.mapping(x, indent+2)
+		case []interface{}:
+			if len(x) == 0 {
+				y.WriteString(dash + "[]\n")
+				continue
+			}
+			inner.list(x, indent+2)
+		default:
+			y.WriteString(fmt.Sprintf("%s%s\n", dash, x))
+			continue
+		}
+		// The item is written a level deeper, so its first line has room for the dash
+		y.WriteString(dash + inner.String()[indent+2:])
+	}
+}
+
+// value writes the value of a key, inline when it is a scalar or empty
+func (y *yamlWriter) value(v interface{}, indent int) {
+	switch x := v.(type) {
+	case schema:
+		if len(x) == 0 {
+			y.WriteString(" {}\n")
+			return
+		}
+		y.WriteString("\n")
+		y.mapping(x, indent+2)
+	case []interface{}:
+		if len(x) == 0 {
+			y.WriteString(" []\n")
+			return
+		}
+		y.WriteString("\n")
+		y.list(x, indent+2)
+	default:
+		y.WriteString(fmt.Sprintf(" %s\n", x))
+	}
+}
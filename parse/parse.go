@@ -3,17 +3,18 @@ package parse
 import (
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
+	"go/types"
 	"io/ioutil"
-	"os"
-	"sort"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 
 	"io"
 
 	log "github.com/Sirupsen/logrus"
+	"golang.org/x/tools/go/packages"
 )
 
 // Parse is responsible for handling all the logic for parsing
@@ -29,18 +30,126 @@ type Parse struct {
 	comments     map[string]string
 	embeds       map[string][]string
 	outfile      io.Writer
+
+	// loaded are the packages of Files, type-checked from source, by import path
+	loaded map[string]*packages.Package
+	// sources are the packages imported from export data whose files have been parsed, by import path
+	sources map[string]source
+	// dir is where packages are loaded from
+	dir string
+	// fset holds the positions of every loaded package
+	fset *token.FileSet
+	// at is the position of the field or type being resolved
+	at token.Pos
+	// failed counts the unserializable fields and types when they fail the parse
+	failed int
+	// names are the names types are written as
+	names map[*types.TypeName]string
+	// taken are the names in use
+	taken map[string]bool
+	// declared are the types declared within Files
+	declared map[*types.TypeName]bool
+	// queue are the types from outside of Files that still have to be parsed
+	queue []*types.TypeName
+	// consts are the typed constants of each scanned package by import path
+	consts map[string]map[*types.TypeName][]constDecl
+	// enums are the constants of each type written as a union of its values
+	enums map[string][]enumValue
+	// scanned are the packages whose struct field comments are indexed
+	scanned map[string]bool
+	// fieldComments are the comments beside struct fields
+	fieldComments map[*types.Var]string
+	// typeParams are the type parameters of each generic type
+	typeParams map[string][]typeParam
+	// interfaces are the interfaces with methods already diagnosed
+	interfaces map[types.Type]bool
+	// flowTypes are the @flowtype directives of the types looked up, "" when there is none
+	flowTypes map[*types.TypeName]string
+	// unionTypes are the members of each interface looked at, nil when it isn't a union
+	unionTypes map[*types.TypeName][]*types.TypeName
+
+	// Options change how types are written
+	Options Options
+
+	// Diagnostics are the types that could not be written exactly
+	Diagnostics []Diagnostic
+
+	// WellKnown are the types written as given rather than parsed, by default DefaultRegistry
+	WellKnown Registry
+	// imports are the import lines of the well-known types written
+	imports map[string]bool
+}
+
+// Options change how types are written
+type Options struct {
+	// EnumObjects writes an object of the Go constant names and their values next to each enum
+	EnumObjects bool
+
+	// NonNullOptionals writes pointers tagged omitempty or omitzero as `name?: T` rather
+	// than `name?: ?T`, since encoding/json leaves a nil pointer out instead of writing null
+	NonNullOptionals bool
+
+	// MatchEncodingJSON writes every field encoding/json writes, rather than only the
+	// tagged ones. Untagged fields are written under their Go name.
+	MatchEncodingJSON bool
+
+	// HoistStructs writes anonymous structs as types of their own, named after the struct
+	// and field holding them as in Person_InnerStruct, rather than inline
+	HoistStructs bool
+
+	// Tuples writes fixed size arrays such as [3]int as [number, number, number]
+	// rather than Array<number>
+	Tuples bool
+
+	// NumberKeys writes integer map keys as number rather than string. encoding/json
+	// writes them as strings, but Flow indexes objects by numbers just as well.
+	NumberKeys bool
+
+	// NullUnions writes nullable types as T | null rather than ?T
+	NullUnions bool
+
+	// NullableCollections writes slice and map fields as nullable, since encoding/json
+	// writes nil ones as null. Fields tagged omitempty or omitzero are optional instead.
+	NullableCollections bool
+
+	// NonNullElements writes pointers within slices, arrays and maps as what they point
+	// to, as in Array<T> rather than Array<?T>
+	NonNullElements bool
+
+	// Unserializable is what is done with fields encoding/json can't marshal, such as
+	// funcs and channels. They are always diagnosed, and left out by default.
+	Unserializable Unserializable
+
+	// AnyInterfaces writes interface{} and any as any rather than mixed, which Flow
+	// won't let be used before its type is checked
+	AnyInterfaces bool
 }
 
 // New returns a new parser
-func New(r bool, f *os.File) *Parse {
+func New(r bool, w io.Writer) *Parse {
 	return &Parse{
-		comments:     make(map[string]string),
-		mappings:     make(map[string][]field),
-		embeds:       make(map[string][]string),
-		baseMappings: make(map[string]field),
-		Files:        []string{},
-		recursive:    r,
-		outfile:      f,
+		comments:      make(map[string]string),
+		mappings:      make(map[string][]field),
+		embeds:        make(map[string][]string),
+		baseMappings:  make(map[string]field),
+		loaded:        make(map[string]*packages.Package),
+		sources:       make(map[string]source),
+		names:         make(map[*types.TypeName]string),
+		taken:         make(map[string]bool),
+		declared:      make(map[*types.TypeName]bool),
+		consts:        make(map[string]map[*types.TypeName][]constDecl),
+		enums:         make(map[string][]enumValue),
+		scanned:       make(map[string]bool),
+		fieldComments: make(map[*types.Var]string),
+		typeParams:    make(map[string][]typeParam),
+		interfaces:    make(map[types.Type]bool),
+		unionTypes:    make(map[*types.TypeName][]*types.TypeName),
+		flowTypes:     make(map[*types.TypeName]string),
+		WellKnown:     DefaultRegistry(),
+		imports:       make(map[string]bool),
+		Files:         []string{},
+		recursive:     r,
+		outfile:       w,
 	}
 }
 
@@ -74,12 +183,11 @@ type field struct {
 	// in the Flow type will be named
 	tags tag
 
-	// Go type, printed as string
-	typ string
+	// Type resolved from the Go type
+	typ *typeExpr
 
-	// Children are used to gain access to nested (not embedded) structs.
-	// Not currently supported by flow, but keeping the logic in case it is soon.
-	children []field
+	// Optional is set when encoding/json may leave the field out, from omitempty or omitzero
+	optional bool
 }
 
 // ParseDir parses a directory for all go files
@@ -110,253 +218,298 @@ func (p *Parse) ParseDir(d string) (e error) {
 
 // ParseFiles parses all files in p.Files to get all go types
 func (p *Parse) ParseFiles() (e error) {
-	var wg sync.WaitGroup
-	for _, fname := range p.Files {
-		wg.Add(1)
-		go func(fname string) {
-			defer wg.Done()
-			fset := token.NewFileSet() // positions are relative to fset
-
-			// Parse the file given in arguments
-			f, err := parser.ParseFile(fset, fname, nil, parser.ParseComments)
-			if err != nil {
-				e = err
-				return
-			}
-			bs, err := ioutil.ReadFile(fname)
-			if err != nil {
-				e = err
-				return
-			}
-			structMap, baseMap := p.parseTypes(f)
-			// Parse structs
-			structKeys := make([]string, 0, len(structMap))
-			for k := range structMap {
-				structKeys = append(structKeys, k)
-			}
-			sort.Strings(structKeys)
-			p.Lock()
-			for _, structName := range structKeys {
-				p.mappings[structName] = p.parseStruct(structMap[structName], structName, bs)
-			}
-			p.Unlock()
-			baseKeys := make([]string, 0, len(baseMap))
-			for k := range baseMap {
-				baseKeys = append(baseKeys, k)
-			}
-			sort.Strings(baseKeys)
-			p.Lock()
-			for _, baseName := range baseKeys {
-				p.baseMappings[baseName] = field{
-					typ:  baseMap[baseName],
-					name: baseName,
-				}
-			}
-			p.Unlock()
-		}(fname)
+	files, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	// Declared types keep their own name, so name them all before anything refers to them
+	for _, f := range files {
+		p.declareTypes(f.syntax, f.info)
+	}
+	for _, f := range files {
+		p.parseTypes(f.syntax, f.info)
+	}
+
+	// Types from other packages are parsed once referenced, and may reference more
+	for len(p.queue) > 0 {
+		obj := p.queue[0]
+		p.queue = p.queue[1:]
+		p.parseExternal(obj)
+	}
+	p.narrowDiscriminators()
+
+	if p.failed > 0 {
+		return fmt.Errorf("%d fields or types can't be marshaled by encoding/json", p.failed)
 	}
-	wg.Wait()
 	return nil
 }
 
-func (p *Parse) parseTypes(f *ast.File) (map[string]*ast.FieldList, map[string]string) {
-	structMap := map[string]*ast.FieldList{}
-	baseMap := make(map[string]string)
-	// range over the structs and fill struct map
-	for _, d := range f.Scope.Objects {
-		if f.Comments != nil {
-			p.Lock()
-			for _, v := range f.Comments {
-				c := v.Text()
-				p.comments[firstWord(c)] = c
-			}
-			p.Unlock()
-		}
-		ts, ok := d.Decl.(*ast.TypeSpec)
-		if !ok {
-			continue
+// declareTypes names every type declared in f
+func (p *Parse) declareTypes(f *ast.File, info *types.Info) {
+	for _, d := range typeDecls(f) {
+		if obj, ok := info.Defs[d.spec.Name].(*types.TypeName); ok {
+			p.declared[obj] = true
+			p.nameOf(obj)
 		}
+	}
+}
 
-		switch ts.Type.(type) {
-		case *ast.StructType:
-			x, ok := ts.Type.(*ast.StructType)
-			if !ok {
-				continue
-			}
-			structMap[ts.Name.String()] = x.Fields
-		case *ast.InterfaceType:
-			continue
-		case *ast.MapType:
-			x, ok := ts.Type.(*ast.MapType)
-			if !ok {
-				continue
-			}
-			baseMap[d.Name] = parseMap(x)
-		case *ast.ArrayType:
-			x, ok := ts.Type.(*ast.ArrayType)
-			if !ok {
-				continue
+func (p *Parse) parseTypes(f *ast.File, info *types.Info) {
+	// Doc comments are matched exactly by parseType, anything else by its first word
+	if f.Comments != nil {
+		for _, v := range f.Comments {
+			c := v.Text()
+			if _, ok := p.comments[firstWord(c)]; !ok {
+				p.comments[firstWord(c)] = c
 			}
-			baseMap[d.Name] = parseArray(x)
-		default:
-			baseMap[d.Name] = fmt.Sprintf("%v", ts.Type)
 		}
 	}
-	return structMap, baseMap
+	// range over the type declarations and fill the mappings
+	for _, d := range typeDecls(f) {
+		name := d.spec.Name.String()
+		if obj, ok := info.Defs[d.spec.Name].(*types.TypeName); ok {
+			name = p.nameOf(obj)
+		}
+		p.parseType(name, d, info)
+	}
 }
 
-func (p *Parse) parseStruct(fs *ast.FieldList, name string, bs []byte) []field {
-	out := []field{}
-	if fs == nil {
-		return out
+// parseType adds a single type declaration to the mappings under name
+func (p *Parse) parseType(name string, d typeDecl, info *types.Info) {
+	ts := d.spec
+	p.at = ts.Pos()
+	if d.doc != nil {
+		p.comments[name] = d.doc.Text()
 	}
 
-	for _, f := range fs.List {
-		newField := field{}
+	obj, _ := info.Defs[ts.Name].(*types.TypeName)
+	if obj != nil && ts.TypeParams != nil && !types.IsInterface(types.Unalias(obj.Type())) {
+		p.typeParams[name] = p.typeParamsOf(obj)
+	}
 
-		if len(f.Names) == 0 {
-			// // Need logic to save types previously declared and declare the fields in those types in here.
-			switch f.Type.(type) {
-			case *ast.Ident:
-				// Treat as embedded struct
-				t := string(bs[f.Type.Pos()-1 : f.Type.End()-1])
-				// p.Embeds[name] = append(p.Embeds[name], t)
-				if f.Comment != nil {
-					newField.comment = f.Comment.Text()
-				}
-				newField.typ = "embedded"
-				newField.tags = tag{
-					flow: flowTag{
-						name: t,
-					},
-				}
-			default:
-				log.Printf("unknown type, %s\n", f.Type)
+	// A @flowtype directive replaces whatever the type would be written as
+	if typ := typeDirective(d.doc); typ != "" {
+		p.baseMappings[name] = field{
+			typ:  &typeExpr{kind: KindRaw, name: typ},
+			name: name,
+		}
+		return
+	}
+
+	// Types in the registry are written as it says, which is what fields using them are written as
+	if obj != nil {
+		if typ, ok := p.wellKnown(obj); ok {
+			p.baseMappings[name] = field{
+				typ:  typ,
+				name: name,
 			}
-			out = append(out, newField)
-			continue
+			return
 		}
+	}
 
-		// Parse tags after looking for embedded types.
-		// Ignore any json-ignore tags
-		if f.Tag == nil || strings.Contains(f.Tag.Value, "json:\"-\"") {
-			continue
+	// Types with their own marshaler are written as what it writes, not by their fields
+	if obj != nil && !obj.IsAlias() && !types.IsInterface(obj.Type()) {
+		if typ := p.marshaled(obj); typ != nil {
+			p.baseMappings[name] = field{
+				typ:  typ,
+				name: name,
+			}
+			return
 		}
+	}
 
-		// If there are JSON tags,  parse it.
-		if strings.Contains(f.Tag.Value, "json:") {
-			newField.name = f.Names[0].String()
-			newField.tags.original = f.Tag.Value
-			if f.Comment != nil {
-				newField.comment = f.Comment.Text()
+	switch ts.Type.(type) {
+	case *ast.StructType:
+		// Unexported structs are never written, whatever name they are written as
+		if !isExported(ts.Name.Name) {
+			return
+		}
+		if st, ok := info.TypeOf(ts.Type).(*types.Struct); ok {
+			fields := p.jsonStruct(st, p.Options.MatchEncodingJSON)
+			if p.Options.HoistStructs {
+				p.hoist(name, fields)
 			}
-			switch f.Type.(type) {
-			case *ast.InterfaceType:
-				newField.typ = "Object"
-			case *ast.MapType:
-				x := f.Type.(*ast.MapType)
-				newField.typ = parseMap(x)
-			case *ast.ArrayType:
-				x := f.Type.(*ast.ArrayType)
-				newField.typ = parseArray(x)
-			case *ast.StructType:
-				// commented out until I can find out how Flow would support nested objects. They may not.
-				// x, ok := field.Type.(*ast.StructType)
-				// if !ok {
-				// 	continue
-				// }
-				// if x.Fields.List == nil || x.Fields.NumFields() == 0 {
-				// 	newField.Type = "object"
-				// 	continue
-				// }
-				// newField.Children = p.parseStruct(x.Fields, bs)
-				newField.typ = "Object"
-			case *ast.StarExpr:
-				x := f.Type.(*ast.StarExpr)
-				t := string(bs[x.Pos()-2 : x.End()-1])
-				t = strings.Replace(t, "*", "?", -1)
-				t = strings.Replace(t, "time.Duration", "string", -1)
-				t = strings.Replace(t, "time.Time", "string", -1)
-
-				if strings.Contains(t, "[]") {
-					newField.typ = strings.Replace(t, "[]", "Array<", -1) + ">"
-				} else {
-					newField.typ = t
-				}
-			default:
-				t := string(bs[f.Type.Pos()-1 : f.Type.End()-1])
-				if strings.Contains(t, "time") {
-					newField.typ = "string"
-				} else {
-					newField.typ = t
-				}
+			p.mappings[name] = fields
+		}
+	case *ast.InterfaceType:
+		if obj == nil {
+			return
+		}
+		if members := p.unionOf(obj); members != nil {
+			p.baseMappings[name] = field{
+				typ:  p.union(members),
+				name: name,
 			}
-			out = append(out, newField)
+		}
+	default:
+		if bad := p.unserializable(info.TypeOf(ts.Type)); bad != nil && p.rejected("type "+name, bad) {
+			return
+		}
+		typ := p.resolve(info.TypeOf(ts.Type))
+		if obj != nil && !obj.IsAlias() {
+			if enum := p.enum(name, obj); enum != nil {
+				typ = enum
+			}
+		}
+		p.baseMappings[name] = field{
+			typ:  typ,
+			name: name,
 		}
 	}
-	return out
 }
 
-func firstWord(value string) string {
-	for i := range value {
-		if value[i] == ' ' {
-			return value[0:i]
+// parseExternal adds a type declared outside of p.Files once it has been referenced
+func (p *Parse) parseExternal(obj *types.TypeName) {
+	name := p.names[obj]
+	d, info, ok := p.declOf(obj)
+	if !ok {
+		log.WithField("type", obj.Pkg().Path()+"."+obj.Name()).Warn("no declaration found")
+		p.baseMappings[name] = field{
+			typ:  p.resolve(obj.Type().Underlying()),
+			name: name,
 		}
+		return
 	}
-	return value
+	p.parseType(name, d, info)
 }
 
-func parseArray(ts *ast.ArrayType) string {
-	var arr string
-	ast.Inspect(ts, func(n ast.Node) bool {
-		var s string
-		switch x := n.(type) {
-		case *ast.BasicLit:
-			s = "?" + x.Value
-		case *ast.Ident:
-			s = x.Name
-		}
+// typeDecl is a declared type and its doc comment
+type typeDecl struct {
+	spec *ast.TypeSpec
+	doc  *ast.CommentGroup
+}
 
-		if s != "" {
-			if s == "error" {
-				s = "string"
-			}
-			arr = fmt.Sprintf("Array<%s>", s)
+// directive returns the rest of the line of a doc comment that starts with name,
+// as in // @flowtype string
+func directive(doc *ast.CommentGroup, name string) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == name || strings.HasPrefix(line, name+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, name)), true
 		}
-		return true
-	})
-	return arr
+	}
+	return "", false
 }
 
-func parseMap(ts *ast.MapType) string {
-	return fmt.Sprintf("{ [key: %s]: %s }", inspectNode(ts.Key), inspectNode(ts.Value))
+// typeDirective returns the type of a @flowtype directive, or "". A @tstype directive is
+// only for TypeScriptEmitter, which reads it from the directives of the declaration.
+func typeDirective(doc *ast.CommentGroup) string {
+	typ, _ := directive(doc, "@flowtype")
+	return typ
 }
 
-// inspectNode checks what is determined to be the value of a node based on a type-assertion of the node.
-func inspectNode(node ast.Node) string {
-	var out string
-	ast.Inspect(node, func(n ast.Node) bool {
-		var s string
-		switch y := n.(type) {
-		case *ast.BasicLit:
-			s = y.Value
-		case *ast.Ident:
-			s = y.Name
-		case *ast.StarExpr:
-			out = "?"
+// flowTypeOf returns the type of a @flowtype directive on the declaration of obj, or ""
+func (p *Parse) flowTypeOf(obj *types.TypeName) string {
+	if typ, ok := p.flowTypes[obj]; ok {
+		return typ
+	}
+	typ := ""
+	if obj.Pkg() != nil {
+		if d, _, ok := p.declOf(obj); ok {
+			typ = typeDirective(d.doc)
 		}
+	}
+	p.flowTypes[obj] = typ
+	return typ
+}
 
-		if s != "" {
-			if s == "error" {
-				s = "string"
-			}
-			out += s
+// typeDecls returns every type declared at the top level of f
+func typeDecls(f *ast.File) []typeDecl {
+	out := []typeDecl{}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
 		}
-		return true
-	})
+		for _, spec := range gd.Specs {
+			out = append(out, typeDecl{spec: spec.(*ast.TypeSpec), doc: docOf(gd, spec)})
+		}
+	}
 	return out
 }
 
+// docOf returns the comment of a type or value spec: the one above it, or beside a value.
+// The parser attaches the comment of an ungrouped declaration to the declaration instead.
+func docOf(gd *ast.GenDecl, spec ast.Spec) *ast.CommentGroup {
+	var doc *ast.CommentGroup
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		doc = s.Doc
+	case *ast.ValueSpec:
+		doc = s.Doc
+		if doc == nil {
+			doc = s.Comment
+		}
+	}
+	if doc == nil && len(gd.Specs) == 1 {
+		doc = gd.Doc
+	}
+	return doc
+}
+
+// newField resolves a struct field of type t, applying the options of its json tag
+func (p *Parse) newField(name, tags string, t types.Type, comment string) field {
+	newField := field{
+		name:    name,
+		comment: comment,
+	}
+	newField.tags.original = tags
+	newField.tags.flow = parseFlowTag(getTag("flow", tags))
+
+	// A type overridden by a flow tag is never resolved, so it isn't written either
+	if newField.tags.flow.typ != "" {
+		newField.typ = &typeExpr{kind: KindRaw, name: newField.tags.flow.typ}
+	} else {
+		newField.typ = p.resolve(t)
+	}
+
+	_, opts := splitTag(lookupTag("json", tags))
+	if b := quoted(t); b != nil && hasOption(opts, "string") {
+		newField.typ = primitive("string")
+		if _, ok := t.(*types.Pointer); ok {
+			newField.typ = &typeExpr{kind: KindNullable, elem: newField.typ}
+		}
+		note := fmt.Sprintf("%s encoded as a string", b.Name())
+		if c := strings.TrimSpace(newField.comment); c != "" {
+			note = fmt.Sprintf("%s (%s)", c, note)
+		}
+		newField.comment = note + "\n"
+	}
+	if p.Options.NullableCollections && !omitted(opts, t) && newField.typ.kind != KindNullable {
+		switch t.Underlying().(type) {
+		case *types.Slice, *types.Map:
+			newField.typ = &typeExpr{kind: KindNullable, elem: newField.typ}
+		}
+	}
+	if omitted(opts, t) {
+		newField.optional = true
+		// A nil pointer is left out rather than written as null
+		if p.Options.NonNullOptionals && newField.typ.kind == KindNullable {
+			newField.typ = newField.typ.elem
+		}
+	}
+
+	// Flow tags have priority over everything else
+	newField.tags.json = getTag("json", tags)
+	if newField.tags.flow.typ != "" {
+		newField.typ = &typeExpr{kind: KindRaw, name: newField.tags.flow.typ}
+	}
+	return newField
+}
+
+func firstWord(value string) string {
+	for i := range value {
+		if value[i] == ' ' {
+			return value[0:i]
+		}
+	}
+	return value
+}
+
 func removeDuplicates(s []string) []string {
 	found := make(map[string]bool)
 	j := 0
@@ -375,29 +528,6 @@ func removeDuplicates(s []string) []string {
 	return cp
 }
 
-// updateTags updates tags in place. If a comma is before an ending quote, it stops at the comma
-func updateTags(f []field) {
-	for i := range f {
-		if strings.Contains(f[i].comment, "a duration") {
-			log.Println(f[i])
-		}
-		// If the type is not exported, ignore the type and all fields
-		// Set it to blank to ignore later
-		if !isExported(f[i].name) {
-			f = []field{}
-			return
-		}
-		flowTags := parseFlowTag(getTag("flow", f[i].tags.original))
-		f[i].tags.json = getTag("json", f[i].tags.original)
-		f[i].tags.flow = flowTags
-		if flowTags.typ != "" {
-			f[i].typ = flowTags.typ
-		} else if f[i].typ == "struct" {
-			updateTags(f[i].children)
-		}
-	}
-}
-
 func parseFlowTag(tag string) flowTag {
 	sp := strings.Split(tag, ".")
 	switch len(sp) {
@@ -415,58 +545,64 @@ func parseFlowTag(tag string) flowTag {
 	}
 }
 
+// getTag returns the name given by one key of the tags, which is everything before the first comma
 func getTag(tag string, tags string) string {
-	loc := strings.Index(tags, fmt.Sprintf("%s:\"", tag))
-	if loc > -1 {
-		bs := []byte(tags)
-		bs = bs[loc+len(tag)+2:]
-		loc = strings.Index(string(bs), "\"")
-		commaLoc := strings.Index(string(bs), ",")
-		if commaLoc > -1 && commaLoc < loc {
-			return string(bs[:commaLoc])
-		}
-		if loc == -1 {
-			return ""
-		}
-		return string(bs[:loc])
+	name, _ := splitTag(lookupTag(tag, tags))
+	return name
+}
+
+// lookupTag returns the whole value of one key of the tags, such as `name,omitempty` for json.
+// The tags may still be quoted as they are in the source.
+func lookupTag(tag string, tags string) string {
+	if unquoted, err := strconv.Unquote(tags); err == nil {
+		tags = unquoted
 	}
-	return ""
+	v, _ := reflect.StructTag(tags).Lookup(tag)
+	return v
+}
+
+// splitTag splits a tag value into the name and the options following it
+func splitTag(v string) (string, []string) {
+	sp := strings.Split(v, ",")
+	return sp[0], sp[1:]
 }
 
-func updateTypes(name string, fields []field) {
-	for i := range fields {
-		fields[i].typ = updateType(fields[i].typ)
-		if len(fields[i].children) > 0 {
-			updateTypes(name, fields[i].children)
+// hasOption reports whether the options of a tag include opt
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
 		}
 	}
+	return false
 }
 
-func updateType(t string) string {
-	// conversions is used in strings.Replacer to replace each key/val pair. Left is Go, right is Flow.
-	conversions := []string{
-		"*", "?",
-		"int64", "number",
-		"int32", "number",
-		"int16", "number",
-		"int8", "number",
-		"int", "number",
-		"uint64", "number",
-		"uint32", "number",
-		"uint16", "number",
-		"uint8", "number",
-		"uint", "number",
-		"uintptr", "number",
-		"byte", "number",
-		"rune", "number",
-		"float32", "number",
-		"float64", "number",
-		"complex64", "number",
-		"complex128", "number",
-		"bool", "boolean"}
-
-	replacer := strings.NewReplacer(conversions...)
-	return replacer.Replace(t)
+// quoted returns the basic type of a field that the json ",string" option writes inside a
+// string, or nil when encoding/json ignores the option for t
+func quoted(t types.Type) *types.Basic {
+	b, ok := deref(t).Underlying().(*types.Basic)
+	if !ok || b.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat|types.IsString) == 0 {
+		return nil
+	}
+	return b
+}
+
+// omitted reports whether encoding/json can leave a field of type t out given its tag options.
+// omitzero drops any zero value, while omitempty never drops structs or arrays that have a length.
+func omitted(opts []string, t types.Type) bool {
+	if hasOption(opts, "omitzero") {
+		return true
+	}
+	if !hasOption(opts, "omitempty") {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		return false
+	case *types.Array:
+		return u.Len() == 0
+	}
+	return true
 }
 
 // isExported returns true if the first character in a string is already capital
@@ -477,12 +613,3 @@ func isExported(s string) bool {
 	l := string(byte(s[0]))
 	return strings.ToUpper(l) == l
 }
-
-// RemoveUnexported removes anything that is not exported from the map used to reference types before writing
-func removeUnexported(m map[string][]field) {
-	for k := range m {
-		if !isExported(k) {
-			delete(m, k)
-		}
-	}
-}
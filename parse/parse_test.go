@@ -1,17 +1,858 @@
 package parse
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+
+	"golang.org/x/tools/go/packages"
 )
 
-func TestParseDir(t *testing.T) {
-	p := New(true)
+// parseTestdata runs the parser over ../testdata and returns the written document
+func parseTestdata(t *testing.T, recursive bool, opts Options) string {
+	_, out := parseTestdataWith(t, recursive, opts, nil)
+	return out
+}
+
+// parseTestdataWith is parseTestdata, returning the parser as well. setup, if not nil, is
+// given the parser before it parses.
+func parseTestdataWith(t *testing.T, recursive bool, opts Options, setup func(*Parse)) (*Parse, string) {
+	p, out, err := writeTestdata(t, recursive, opts, setup, FlowEmitter{})
+	if err != nil {
+		t.Fatal("error:", err)
+	}
+	return p, out
+}
+
+// emitTestdata parses testdata recursively and writes it with e
+func emitTestdata(t *testing.T, opts Options, e Emitter) string {
+	_, out := emitTestdataWith(t, opts, e)
+	return out
+}
+
+// emitTestdataWith is emitTestdata, returning the parser as well
+func emitTestdataWith(t *testing.T, opts Options, e Emitter) (*Parse, string) {
+	p, out, err := writeTestdata(t, true, opts, nil, e)
+	if err != nil {
+		t.Fatal("error:", err)
+	}
+	return p, out
+}
+
+// writeTestdata parses ../testdata, after setup if it is not nil, and writes it with e.
+// Nothing is written when parsing fails, and the error is returned for tests expecting one.
+func writeTestdata(t *testing.T, recursive bool, opts Options, setup func(*Parse), e Emitter) (*Parse, string, error) {
+	var buf bytes.Buffer
+	p := New(recursive, &buf)
+	p.Options = opts
+	if setup != nil {
+		setup(p)
+	}
 
 	if err := p.ParseDir("../testdata"); err != nil {
-		t.Log("error:", err)
+		t.Fatal("error:", err)
+	}
+	if err := p.ParseFiles(); err != nil {
+		return p, "", err
+	}
+	if err := p.Emit(e); err != nil {
+		t.Fatal("error:", err)
+	}
+	return p, buf.String(), nil
+}
+
+// diagnosticOf returns the diagnostic of a type in testdata, if there is one
+func diagnosticOf(p *Parse, name string) *Diagnostic {
+	for i, d := range p.Diagnostics {
+		if strings.HasSuffix(d.Type, "/testdata."+name) {
+			return &p.Diagnostics[i]
+		}
+	}
+	return nil
+}
+
+// loads are the packages loaded so far, by how they were loaded. Every test parses the
+// same testdata, so each test binary only loads it once.
+var loads = make(map[string][]*packages.Package)
+
+func init() {
+	load := loadPackages
+	loadPackages = func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		key := fmt.Sprintf("%d %s %s", cfg.Mode, cfg.Dir, strings.Join(patterns, " "))
+		if pkgs, ok := loads[key]; ok {
+			return pkgs, nil
+		}
+		pkgs, err := load(cfg, patterns...)
+		if err == nil {
+			loads[key] = pkgs
+		}
+		return pkgs, err
+	}
+}
+
+// wantDecls checks that each declaration of out has what is wanted of it. Declarations are
+// named as they are written, as in Person, StatusValues or isPerson. what says what out was
+// written with, if anything.
+func wantDecls(t *testing.T, what string, out string, want map[string][]string) {
+	t.Helper()
+	if what != "" {
+		what += ": "
+	}
+	names := []string{}
+	for name := range want {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		decl := declIn(out, name)
+		if decl == "" {
+			t.Errorf("%sno declaration of %s in:\n%s", what, name, out)
+			continue
+		}
+		for _, w := range want[name] {
+			if !strings.Contains(decl, w) {
+				t.Errorf("%smissing %q in:\n%s", what, w, decl)
+			}
+		}
+	}
+}
+
+// declIn returns the declaration of name in out, from its doc comment to the blank line
+// after it, or "" if there is none
+func declIn(out, name string) string {
+	for _, block := range strings.Split(out, "\n\n") {
+		for _, line := range strings.Split(block, "\n") {
+			if !strings.HasPrefix(line, "export ") {
+				continue
+			}
+			words := strings.Fields(strings.TrimPrefix(strings.TrimPrefix(line, "export "), "declare "))
+			if len(words) > 1 && strings.FieldsFunc(words[1], func(r rune) bool { return strings.ContainsRune("<:(", r) })[0] == name {
+				return "\n" + block + "\n"
+			}
+			break
+		}
+	}
+	return ""
+}
+
+func TestParseDir(t *testing.T) {
+	out := parseTestdata(t, true, Options{})
+
+	wantDecls(t, "", out, map[string][]string{
+		"Payrate":   {"export type Payrate = number\n"},
+		"Errors":    {"export type Errors = Array<string>\n"},
+		"MapNumPtr": {"export type MapNumPtr = { [key: string]: Animal }\n"},
+		"Person":    {"\tnullable: ?string,\n", "\tanimals_array_ptr: ?Array<Animal>,"},
+		"Maps": {
+			"\tmap_of_slice: { [key: string]: Array<Person> },\n",
+			"\tslice_of_map_of_slices: Array<{ [key: string]: Array<Person> }>,\n",
+		},
+		"Unnamed":         {"export type Unnamed = {\n\tCount?: number,\n\tID: string,\t// int64 encoded as a string\n}\n"},
+		"EmbeddedAnimal2": {"\tdate: string,\n", "\tduration: number,"},
+		"Time":            {"\tuptime: Uptime,\n"},
+		"Uptime":          {"export type Uptime = number\n"},
+		// Only the constants declared with iota make an enum, not others in their group
+		"Level":   {"export type Level = 0 | 1\n"},
+		"Timeout": {"export type Timeout = number\n"},
+	})
+}
+
+func TestParseExternal(t *testing.T) {
+	// Without recursion, the shared and teams packages are only reached through imports
+	out := parseTestdata(t, false, Options{})
+
+	wantDecls(t, "", out, map[string][]string{
+		"Account":       {"\towner: User,\n", "\tteam: Team,\n", "\tadmins: Array<User>,\n", "\tcreated: Month,\n"},
+		"Month":         {"export type Month = 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12\n"},
+		"Role":          {"export type Role = string\n"},
+		"User":          {"// User is referenced through a renamed import\nexport type User = {\n", "\tpet: shared_Animal,\n"},
+		"shared_Animal": {"export type shared_Animal = {\n\tlegs: number,\n}\n"},
+	})
+}
+
+func TestParseEnums(t *testing.T) {
+	out := parseTestdata(t, true, Options{EnumObjects: true})
+
+	wantDecls(t, "", out, map[string][]string{
+		"Status":       {"export type Status = 1 | 2 | 4\n"},
+		"StatusValues": {"export const StatusValues = {\n\tActive: (1: Status),\n\tSuspended: (2: Status),\n\tDeleted: (4: Status),\n}\n"},
+		"Month":        {"export type Month = 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12\n"},
+		"Access":       {"export type Access =\n\t| 'admin'\t// AccessAdmin can do anything\n\t| 'member'\t// AccessMember can read and write\n\t| 'guest'\n"},
+		"AccessValues": {"\tAccessGuest: ('guest': Access),\n"},
+		// Constants not counted with iota are units rather than an enum
+		"Uptime": {"export type Uptime = number\n"},
+		// Only the constants declared with iota make an enum, not others in their group
+		"Level":   {"export type Level = 0 | 1\n"},
+		"Timeout": {"export type Timeout = number\n"},
+	})
+}
+
+func TestParseOptionals(t *testing.T) {
+	for _, c := range []struct {
+		opts Options
+		want map[string][]string
+	}{
+		{Options{}, map[string][]string{
+			"Person": {"\thascomma?: string,\n"},
+			"Optionals": {
+				"\tname?: ?string,\n",
+				"\tcount?: number,\n",
+				"\ttags?: Array<string>,\n",
+				"\tanimal: Animal,",
+				"\tcreated?: string,\n",
+			},
+		}},
+		{Options{NonNullOptionals: true}, map[string][]string{
+			"Optionals": {"\tname?: string,\n"},
+			// Without omitempty, nil pointers are still written as null
+			"Person": {"\tnullable: ?string,\n"},
+		}},
+	} {
+		out := parseTestdata(t, true, c.opts)
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+	}
+}
+
+func TestParseQuoted(t *testing.T) {
+	out := parseTestdata(t, true, Options{})
+
+	wantDecls(t, "", out, map[string][]string{
+		"Identifiers": {
+			"\tid: string,\t// ID of the thing (int64 encoded as a string)\n",
+			"\tparent_id?: ?string,\t// int64 encoded as a string\n",
+			"\tenabled: string,\t// bool encoded as a string\n",
+			"\tchildren: Array<number>,\t// the string option only applies to scalars\n",
+		},
+	})
+}
+
+func TestParseEmbedded(t *testing.T) {
+	out := parseTestdata(t, true, Options{})
+
+	wantDecls(t, "", out, map[string][]string{
+		"Embeds":          {"export type Embeds = {\n\tdoohickey2: string,\t// doohickey two\n\tid: number,\n\tuser: User,\n\tPayrate: Payrate,\n\tdoohickey: string,\n\tsome_horse_attrib: string,\n}\n"},
+		"Wire":            {"export type Wire = {\n\t'-': string,\n\trenamed: string,\n\tonly_a: number,\n}\n"},
+		"EmbeddedAnimal2": {"export type EmbeddedAnimal2 = {\n\tbreed: string,\n\tname: string,\n\tbirthday: string,"},
+	})
+}
+
+func TestParseEncodingJSON(t *testing.T) {
+	out := parseTestdata(t, true, Options{MatchEncodingJSON: true})
+
+	wantDecls(t, "", out, map[string][]string{
+		"Wire":   {"export type Wire = {\n\tUntagged: string,\n\t'-': string,\n\trenamed: string,\n\tonly_a: number,\n}\n"},
+		"Animal": {"export type Animal = {|\n\tbreed: string,\n\tname: string,\n\tNoTag: string,\n|}\n"},
+	})
+}
+
+func TestParseNested(t *testing.T) {
+	for _, c := range []struct {
+		opts Options
+		want map[string][]string
+	}{
+		{Options{}, map[string][]string{
+			"Nested": {
+				"\titems: Array<{\n\t\tsku: string,\n\t}>,\n",
+				"\tlookup: { [key: string]: ?{\n\t\tcount: number,\t// how many there are\n\t} },\n",
+			},
+			"Person": {
+				"\t\tchild: {\n\t\t\ttoys: Array<string>,\n",
+				"\t\t\t\tempty_struct: {},\n\t\t\t},\n\t\t},\n\t},\t// I have a comment in a nested struct\n",
+			},
+		}},
+		{Options{HoistStructs: true}, map[string][]string{
+			"Nested":                           {"\titems: Array<Nested_Items>,\n", "\tlookup: { [key: string]: ?Nested_Lookup },\n"},
+			"Nested_Lookup":                    {"export type Nested_Lookup = {\n\tcount: number,\t// how many there are\n}\n"},
+			"Person":                           {"\tinner_struct: Person_InnerStruct,"},
+			"Person_InnerStruct":               {"\tchild: Person_InnerStruct_Child,\n"},
+			"Person_InnerStruct_Child":         {"\tfriends: Person_InnerStruct_Child_Friends,\n"},
+			"Person_InnerStruct_Child_Friends": {"\tempty_struct: {},\n"},
+			"Settings":                         {"export type Settings = {\n\tinner: Settings_Inner_2,\n}\n"},
+			"Settings_Inner":                   {"export type Settings_Inner = {\n\ty: number,\n}\n"},
+			"Settings_Inner_2":                 {"export type Settings_Inner_2 = {\n\tx: number,\n}\n"},
+			"Box":                              {"export type Box<T> = {\n\tmeta: Box_Meta<T>,\n\ttags: Array<Box_Tags>,\n}\n"},
+			"Box_Meta":                         {"export type Box_Meta<T> = {\n\tval: T,\n"},
+			"Box_Tags":                         {"export type Box_Tags = {\n"},
+		}},
+	} {
+		out := parseTestdata(t, true, c.opts)
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+	}
+}
+
+func TestParseArrays(t *testing.T) {
+	for _, c := range []struct {
+		opts Options
+		want map[string][]string
+	}{
+		{Options{}, map[string][]string{
+			"Binary": {"\tdata: string,\n", "\traw: mixed,\n", "\tpoint: Array<number>,\n", "\thash: Array<number>,"},
+			"Blob":   {"export type Blob = string\n"},
+		}},
+		{Options{Tuples: true}, map[string][]string{
+			"Binary": {"\tpoint: [number, number, number],\n", "\thash: [number, number, number, number],"},
+		}},
+	} {
+		out := parseTestdata(t, true, c.opts)
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+	}
+}
+
+func TestParseMarshalers(t *testing.T) {
+	p, out := parseTestdataWith(t, true, Options{}, nil)
+
+	wantDecls(t, "", out, map[string][]string{
+		"Money": {"export type Money = mixed\n"},
+		"Code":  {"export type Code = string\n"},
+		"Price": {"\tamount: string,\n", "\ttotal: Money,\n", "\tcodes: Array<?Code>,\n", "\tbig: string,"},
+		// Marshalers promoted from a type in the registry or with a @flowtype are written as it is
+		"Stamped": {"export type Stamped = string\n"},
+		"Release": {"export type Release = string\n"},
+	})
+	if strings.Contains(out, "Int =") {
+		t.Errorf("big.Int is only referenced with a flow tag but was written:\n%s", out)
+	}
+
+	if d := diagnosticOf(p, "Money"); d == nil || !d.Pos.IsValid() {
+		t.Errorf("missing diagnostic for Money in %v", p.Diagnostics)
+	}
+	for _, name := range []string{"Price", "Stamped", "Release"} {
+		if d := diagnosticOf(p, name); d != nil {
+			t.Errorf("unexpected diagnostic %s", d)
+		}
+	}
+}
+
+func TestParseWellKnown(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "types.json")
+	err := ioutil.WriteFile(config, []byte(`{
+		"github.com/natdm/goflow/testdata.Money": {"type": "Dollars", "import": "import type { Dollars } from './money'"},
+		"time.Duration": {"type": "string"}
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, out := parseTestdataWith(t, true, Options{}, func(p *Parse) {
+		if err := p.WellKnown.Load(config); err != nil {
+			t.Fatal("error:", err)
+		}
+	})
+
+	if !strings.Contains(out, "// DO NOT EDIT -- automatically generated by goflow\n\nimport type { Dollars } from './money'\n\n") {
+		t.Errorf("missing the import of Dollars in:\n%s", out)
+	}
+	wantDecls(t, "", out, map[string][]string{
+		"WellKnowns": {
+			"\tnickname: ?string,\n",
+			"\tvisits: ?number,\n",
+			"\tscore: number,\n",
+			"\tbalance: number,\n",
+			"\ttimeout: string,\n",
+			"\tfault: string,\n",
+		},
+		"Price": {"\ttotal: Dollars,\n"},
+		"Money": {"export type Money = Dollars\n"},
+	})
+	// The registry says what Money is, so its MarshalJSON is no concern
+	if d := diagnosticOf(p, "Money"); d != nil {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+}
+
+func TestParseGenerics(t *testing.T) {
+	p, out := parseTestdataWith(t, true, Options{}, nil)
+
+	wantDecls(t, "", out, map[string][]string{
+		"Page":    {"export type Page<T> = {\n\titems: Array<T>,\n\tnext: string,\n}\n"},
+		"Keyed":   {"export type Keyed<K: string | number, V> = {\n\tvalues: { [key: K]: V },\n}\n"},
+		"Labeled": {"export type Labeled<T> = {\n"},
+		"List":    {"export type List<T> = Array<T>\n"},
+		"Envelopes": {
+			"\tusers: Page<User>,\n",
+			"\tpages: Page<Page<number>>,\n",
+			"\tids: List<number>,\n",
+			"\tcounts: Keyed<Access, number>,\n",
+		},
+	})
+	if d := diagnosticOf(p, "Labeled"); d == nil || !strings.Contains(d.Message, "fmt.Stringer") {
+		t.Errorf("missing diagnostic for Labeled in %v", p.Diagnostics)
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	out := parseTestdata(t, true, Options{})
+
+	wantDecls(t, "", out, map[string][]string{
+		"UserID":  {"export type UserID = string\n"},
+		"AdminID": {"export type AdminID = UserID\n"},
+		"OwnerID": {"export type OwnerID = AdminID\n"},
+		"TeamRef": {"export type TeamRef = Team\n"},
+		"Member":  {"export type Member = User\n"},
+		"Pair":    {"export type Pair<V> = Keyed<string, V>\n"},
+		"Aliases": {
+			"\tuser: UserID,\n",
+			"\towner: OwnerID,\n",
+			"\tteam: TeamRef,\n",
+			"\tpairs: Pair<number>,\n",
+			"\tany: mixed,\n",
+		},
+	})
+}
+
+func TestParseInterfaces(t *testing.T) {
+	for _, c := range []struct {
+		opts Options
+		want map[string][]string
+	}{
+		{Options{}, map[string][]string{
+			"Dynamic": {
+				"\tvalue: mixed,\n",
+				"\tvalues: Array<mixed>,\n",
+				"\textra: { [key: string]: mixed },\n",
+				"\tpayload: mixed,\n",
+				"\tlabel: mixed,\n",
+				"\tshape: mixed,\n",
+			},
+		}},
+		{Options{AnyInterfaces: true}, map[string][]string{
+			"Dynamic": {
+				"\tvalue: any,\n",
+				"\tvalues: Array<any>,\n",
+				"\textra: { [key: string]: any },\n",
+			},
+		}},
+	} {
+		p, out := parseTestdataWith(t, true, c.opts, nil)
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+
+		diagnosed := map[string]bool{}
+		for _, d := range p.Diagnostics {
+			if strings.HasPrefix(d.Message, "is an interface with methods") {
+				diagnosed[d.Type] = d.Pos.IsValid()
+			}
+		}
+		for _, want := range []string{"fmt.Stringer", "interface{Area() float64}"} {
+			if !diagnosed[want] {
+				t.Errorf("%+v: missing diagnostic for %s in %v", c.opts, want, p.Diagnostics)
+			}
+		}
+	}
+}
+
+func TestParseUnions(t *testing.T) {
+	p, out := parseTestdataWith(t, true, Options{}, nil)
+
+	wantDecls(t, "", out, map[string][]string{
+		"Shape":   {"export type Shape = Circle | Square\n"},
+		"Event":   {"export type Event = Opened | Closed\n"},
+		"Circle":  {"export type Circle = {\n\tkind: 'circle',\n"},
+		"Square":  {"export type Square = {\n\tkind: 'Square',\n"},
+		"Closed":  {"export type Closed = {\n\tkind: 'Closed',\n"},
+		"Drawing": {"\tshapes: Array<Shape>,\n", "\tlast: Event,\n"},
+	})
+	if strings.Contains(out, "hidden") {
+		t.Errorf("unexported member written in:\n%s", out)
+	}
+	for _, member := range []string{"hidden", "Sized"} {
+		found := false
+		for _, d := range p.Diagnostics {
+			found = found || strings.HasSuffix(d.Type, "/testdata.Shape") && strings.Contains(d.Message, member)
+		}
+		if !found {
+			t.Errorf("no diagnostic of the member %s in %v", member, p.Diagnostics)
+		}
+	}
+}
+
+func TestParseMapKeys(t *testing.T) {
+	for _, c := range []struct {
+		opts Options
+		want map[string][]string
+	}{
+		{Options{}, map[string][]string{
+			"Keys": {
+				"\tby_id: { [key: string]: string },\n",
+				"\tby_status: { [key: string]: number },\n",
+				"\tby_access: { [key: Access]: number },\n",
+				"\tby_coord: { [key: string]: number },\n",
+				"\tby_float: { [key: string]: string },\n",
+			},
+		}},
+		{Options{NumberKeys: true}, map[string][]string{
+			"Keys": {
+				"\tby_id: { [key: number]: string },\n",
+				"\tby_status: { [key: Status]: number },\n",
+				"\tby_coord: { [key: string]: number },\n",
+			},
+		}},
+	} {
+		p, out := parseTestdataWith(t, true, c.opts, nil)
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+
+		found := false
+		for _, d := range p.Diagnostics {
+			if d.Type == "float64" && strings.HasSuffix(d.Pos.Filename, "fixtures.go") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%+v: missing diagnostic for float64 keys in %v", c.opts, p.Diagnostics)
+		}
+	}
+}
+
+func TestParseNullability(t *testing.T) {
+	for _, c := range []struct {
+		opts Options
+		want map[string][]string
+	}{
+		{Options{}, map[string][]string{
+			"Nullables": {
+				"\tpets: Array<?Animal>,\n",
+				"\towners: { [key: string]: ?Person },\n",
+				"\tgrid: Array<?number>,\n",
+				"\ttags?: Array<string>,\n",
+				"\tscores: { [key: string]: number },\n",
+				"\tptr: ?string,\n",
+			},
+		}},
+		{Options{NullUnions: true, NullableCollections: true, NonNullElements: true}, map[string][]string{
+			"Nullables": {
+				"\tpets: Array<Animal> | null,\n",
+				"\towners: { [key: string]: Person } | null,\n",
+				"\tgrid: Array<number>,\n",
+				"\ttags?: Array<string>,\n",
+				"\tscores: { [key: string]: number } | null,\n",
+				"\tptr: string | null,\n",
+			},
+		}},
+	} {
+		out := parseTestdata(t, true, c.opts)
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+	}
+}
+
+func TestParseUnserializable(t *testing.T) {
+	for _, c := range []struct {
+		opts  Options
+		want  map[string][]string
+		fails bool
+	}{
+		{opts: Options{}, want: map[string][]string{
+			"Unserializables": {"export type Unserializables = {\n\tname: string,\n\toverride: () => void,\n}\n"},
+		}},
+		{opts: Options{Unserializable: MixedUnserializable}, want: map[string][]string{
+			"Unserializables": {
+				"\tcallback: mixed,\n",
+				"\tevents: mixed,\n",
+				"\thandlers: Array<Handler>,\n",
+				"\tphase: mixed,\n",
+				"\tpointer: mixed,\n",
+			},
+			"Handler": {"export type Handler = mixed\n"},
+		}},
+		{opts: Options{Unserializable: FailUnserializable}, fails: true},
+	} {
+		p, out, err := writeTestdata(t, true, c.opts, nil, FlowEmitter{})
+		if (err != nil) != c.fails {
+			t.Fatalf("%+v: error = %v, want an error %v", c.opts, err, c.fails)
+		}
+		wantDecls(t, fmt.Sprintf("%+v", c.opts), out, c.want)
+
+		diagnosed := map[string]bool{}
+		for _, d := range p.Diagnostics {
+			if strings.Contains(d.Message, "can't be marshaled") && strings.HasSuffix(d.Pos.Filename, "fixtures.go") {
+				diagnosed[d.Type] = true
+			}
+		}
+		for _, want := range []string{"func() string", "chan int", "complex128", "unsafe.Pointer", "func(string) error"} {
+			if !diagnosed[want] {
+				t.Errorf("%+v: missing diagnostic for %s in %v", c.opts, want, p.Diagnostics)
+			}
+		}
+		if diagnosed["func()"] {
+			t.Errorf("%+v: a field with a flow type was diagnosed", c.opts)
+		}
+	}
+}
+
+func TestParseFlowTypeDirective(t *testing.T) {
+	p, out := parseTestdataWith(t, true, Options{}, nil)
+
+	wantDecls(t, "", out, map[string][]string{
+		"Location": {
+			"// @flowtype {| lat: number, lng: number |}\n// @tstype { lat: number; lng: number }\n",
+			"\nexport type Location = {| lat: number, lng: number |}\n",
+		},
+		"Version":  {"export type Version = string\n"},
+		"Notifier": {"export type Notifier = (message: string) => void\n"},
+		"Locator":  {"export type Locator = Location\n"},
+		"Places": {
+			"\thome: Location,\n",
+			"\tvisited: Array<Location>,\n",
+			"\tversion: Version,\n",
+			"\tnotify: Notifier,\n",
+			"\tnear: Locator,\n",
+		},
+		// @tstype is for TypeScript alone
+		"Opaque": {"// @tstype unknown\nexport type Opaque = {\n\tnote: string,\n}\n"},
+	})
+	for _, name := range []string{"Location", "Version", "Notifier", "Places"} {
+		if d := diagnosticOf(p, name); d != nil {
+			t.Errorf("unexpected diagnostic %s", d)
+		}
+	}
+}
+
+func TestParseTypeScript(t *testing.T) {
+	for _, c := range []struct {
+		e    TypeScriptEmitter
+		want map[string][]string
+	}{
+		{TypeScriptEmitter{}, map[string][]string{
+			"Person":       {"export interface Person {\n", "\tnullable: string | null;\n"},
+			"Dynamic":      {"\tvalue: unknown;\n"},
+			"Nullables":    {"\tpets: Array<Animal | null>;\n", "\ttags?: Array<string>;\n"},
+			"Animal":       {"// @strict\nexport interface Animal {\n"},
+			"Status":       {"export type Status = 1 | 2 | 4\n"},
+			"StatusValues": {"export const StatusValues = {\n\tActive: 1 as Status,\n"},
+			"Page":         {"export interface Page<T> {\n\titems: Array<T>;\n"},
+			"Keyed":        {"export interface Keyed<K extends string | number, V> {\n\tvalues: Partial<Record<K, V>>;\n}\n"},
+			"Keys":         {"\tby_id: { [key: string]: string };\n", "\tby_access: Partial<Record<Access, number>>;\n"},
+			"Location":     {"export type Location = { lat: number; lng: number }\n"},
+			"Opaque":       {"export type Opaque = unknown\n"},
+			"Notifier":     {"export type Notifier = unknown\n"},
+			"Dict":         {"export type Dict<K, V> = { [key: string]: V }\n"},
+			"Nested":       {"\tlookup: { [key: string]: {\n\t\tcount: number;\t// how many there are\n\t} | null };\n"},
+		}},
+		{TypeScriptEmitter{Declarations: true}, map[string][]string{
+			"StatusValues": {"export declare const StatusValues: {\n\treadonly Active: 1;\n"},
+		}},
+	} {
+		p, out := emitTestdataWith(t, Options{EnumObjects: true}, c.e)
+		if d := diagnosticOf(p, "Notifier"); d == nil || !strings.Contains(d.Message, "@tstype") {
+			t.Errorf("%+v: Notifier not diagnosed: %v", c.e, d)
+		}
+		wantDecls(t, fmt.Sprintf("%+v", c.e), out, c.want)
+		for _, flow := range []string{"@flow\n", "?", "{|", "mixed", ": Status)", "<T: "} {
+			for _, line := range strings.Split(out, "\n") {
+				if strings.Contains(line, flow) && !strings.HasPrefix(strings.TrimSpace(line), "//") && !strings.Contains(line, "?:") {
+					t.Errorf("%+v: Flow syntax %q in %q", c.e, flow, line)
+				}
+			}
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	out := emitTestdata(t, Options{}, JSONSchemaEmitter{})
+
+	var doc struct {
+		Schema string                     `json:"$schema"`
+		Defs   map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if doc.Schema != JSONSchemaDraft {
+		t.Errorf("$schema is %q", doc.Schema)
+	}
+
+	for name, want := range map[string][]string{
+		"Person": {
+			`"type":"object"`,
+			`"name":{"description":"This is a name comment","type":"string"}`,
+			`"age":{"type":"integer"}`,
+			`"nullable":{"type":["string","null"]}`,
+			`"animals_array_ptr_2":{"description":"I hold pointers","type":"array","items":{"anyOf":[{"$ref":"#/$defs/Animal"},{"type":"null"}]}}`,
+			`"required":["name","age",`,
+		},
+		"Animal":   {`"additionalProperties":false`},
+		"Status":   {`"enum":[1,2,4]`},
+		"Payrate":  {`"type":"integer"`},
+		"Location": {`"description":"Location is written as its coordinates, whatever its fields","type":"object","properties":{"lat":{"type":"number"}`},
+		"Locator":  {`"$ref":"#/$defs/Location"`},
+		"Page":     {`"items":{"type":"array","items":{}}`},
+	} {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, doc.Defs[name]); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, w := range want {
+			if !strings.Contains(compact.String(), w) {
+				t.Errorf("%s: missing %s in %s", name, w, compact.String())
+			}
+		}
+	}
+
+	// Fields that are omitempty or pointers may be left out
+	var person struct {
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(doc.Defs["Person"], &person); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range person.Required {
+		if r == "nullable" || r == "hascomma" || r == "animals_array_ptr" {
+			t.Errorf("Person requires %s", r)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	out := emitTestdata(t, Options{}, OpenAPIEmitter{})
+
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if doc.OpenAPI != OpenAPIVersion {
+		t.Errorf("openapi is %q", doc.OpenAPI)
+	}
+	for name, want := range map[string]string{
+		"Person":  `"animals_array_ptr_2":{"description":"I hold pointers","type":"array","items":{"anyOf":[{"$ref":"#/components/schemas/Animal"},{"type":"null"}]}}`,
+		"Animal":  `"description":"Animal is anything, but should probably have a master","type":"object"`,
+		"Locator": `"$ref":"#/components/schemas/Location"`,
+	} {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, doc.Components.Schemas[name]); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(compact.String(), want) {
+			t.Errorf("%s: missing %s in %s", name, want, compact.String())
+		}
+	}
+
+	out = emitTestdata(t, Options{}, OpenAPIEmitter{YAML: true, Title: "pets", Version: "1.2.0"})
+	for _, want := range []string{
+		"openapi: \"3.1.0\"\ninfo:\n  title: pets\n  version: \"1.2.0\"\ncomponents:\n  schemas:\n",
+		"    Animal:\n      description: Animal is anything, but should probably have a master\n      type: object\n",
+		"      additionalProperties: false\n",
+		"        nullable:\n          type:\n            - string\n            - \"null\"\n",
+		"          items:\n            anyOf:\n              - $ref: \"#/components/schemas/Animal\"\n              - type: \"null\"\n",
+		"    Status:\n      description: Status is counted with iota, so it should be a union of its values\n      enum:\n        - 1\n        - 2\n        - 4\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestFlowGuards(t *testing.T) {
+	out := emitTestdata(t, Options{}, FlowEmitter{Guards: true})
+	wantDecls(t, "", out, map[string][]string{
+		"isAnimal": {
+			"export function isAnimal(x: mixed): boolean %checks {\n\treturn (\n\t\ttypeof x === 'object' && x !== null && !Array.isArray(x) &&\n\t\ttypeof x.breed === 'string' &&\n",
+			"\t\tObject.keys(x).every((k0) => ['breed', 'name'].includes(k0))\n\t)\n}\n",
+		},
+		"isPerson": {
+			"\t\tNumber.isInteger(x.age) &&\n",
+			"\t\t(x.nullable == null || typeof x.nullable === 'string') &&\n",
+			"\t\t(x.hascomma === undefined || typeof x.hascomma === 'string') &&\n",
+			"\t\t(Array.isArray(x.animals_array_ptr_2) && x.animals_array_ptr_2.every((e0) => (e0 == null || isAnimal(e0)))) &&\n",
+		},
+		"isKeys":    {"Object.keys(x.by_access).every((k0) => isAccess(k0) && Number.isInteger(x.by_access[k0]))"},
+		"isMaps":    {"every((e0) => (typeof e0 === 'object' && e0 !== null && !Array.isArray(e0) && Object.keys(e0).every((k1) => (Array.isArray(e0[k1]) && e0[k1].every((e2) => isPerson(e2))))))"},
+		"isStatus":  {"export function isStatus(x: mixed): boolean %checks {\n\treturn (\n\t\t(x === 1 || x === 2 || x === 4)\n\t)\n}\n"},
+		"isPlaces":  {"\t\tisLocator(x.near)\n"},
+		"isLocator": {"export function isLocator(x: mixed): boolean %checks {\n\treturn (\n\t\tisLocation(x)\n"},
+	})
+	// Each type is followed by its guard
+	if !strings.Contains(out, "|}\n\nexport function isAnimal(") {
+		t.Errorf("isAnimal is not after Animal in:\n%s", out)
+	}
+
+	if out := parseTestdata(t, true, Options{}); strings.Contains(out, "export function") {
+		t.Error("guards written without Guards")
+	}
+}
+
+func TestNameOf(t *testing.T) {
+	p := New(false, &bytes.Buffer{})
+	typeName := func(path, name string) *types.TypeName {
+		pkg := types.NewPackage(path, filepath.Base(path))
+		return types.NewTypeName(token.NoPos, pkg, name, nil)
+	}
+
+	// Every Animal gets a name of its own, however many packages declare one
+	for _, c := range []struct {
+		obj  *types.TypeName
+		want string
+	}{
+		{typeName("example.com/models", "Animal"), "Animal"},
+		{typeName("example.com/a/shared", "Animal"), "shared_Animal"},
+		{typeName("example.com/b/shared", "Animal"), "shared_Animal_2"},
+		{typeName("example.com/models", "shared_Animal_3"), "shared_Animal_3"},
+		{typeName("example.com/c/shared", "Animal"), "shared_Animal_4"},
+	} {
+		if got := p.nameOf(c.obj); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.obj, got, c.want)
+		}
+	}
+}
+
+// nameEmitter writes the name of each declaration, one a line
+type nameEmitter struct{}
+
+func (nameEmitter) Extension() string { return ".txt" }
+
+func (nameEmitter) Emit(w io.Writer, doc *Document) error {
+	for _, d := range doc.Decls {
+		if _, err := fmt.Fprintln(w, d.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestEmitter(t *testing.T) {
+	RegisterEmitter("names", nameEmitter{})
+	e, ok := LookupEmitter("names")
+	if !ok {
+		t.Fatal("names emitter not registered")
+	}
+	if _, ok := LookupEmitter("flow"); !ok {
+		t.Error("flow emitter not registered")
+	}
+
+	out := emitTestdata(t, Options{}, e)
+	for _, want := range []string{"Animal\n", "Person\n", "Status\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "export") {
+		t.Errorf("unexpected Flow in:\n%s", out)
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	for _, c := range []struct {
+		in   constant.Value
+		want string
+	}{
+		{constant.MakeInt64(-1), "-1"},
+		{constant.MakeString("admin"), "'admin'"},
+		{constant.MakeString(`it's "quoted"`), `'it\'s "quoted"'`},
+		{constant.MakeString("two\nlines"), `'two\nlines'`},
+	} {
+		if got := literal(c.in); got != c.want {
+			t.Errorf("literal(%v) = %s, want %s", c.in, got, c.want)
+		}
 	}
-	bs, _ := json.MarshalIndent(p.Mappings, "", "\t")
-	fmt.Println(string(bs))
 }
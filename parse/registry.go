@@ -0,0 +1,84 @@
+package parse
+
+import (
+	"encoding/json"
+	"go/types"
+	"io/ioutil"
+)
+
+// WellKnown is how a type from outside of the parsed files is written
+type WellKnown struct {
+	// Type is the Flow type written for it
+	Type string `json:"type"`
+
+	// Import is a line the Flow type needs at the top of the document,
+	// such as import type { UUID } from './uuid'
+	Import string `json:"import,omitempty"`
+
+	// Nullable writes the type as ?Type, for types that hold null of their own
+	Nullable bool `json:"nullable,omitempty"`
+}
+
+// Registry holds well-known types by their package qualified name, as in time.Time
+// or database/sql.NullString. Predeclared types go by their name, as in error.
+type Registry map[string]WellKnown
+
+// DefaultRegistry returns the types that are written the same way by every project
+func DefaultRegistry() Registry {
+	return Registry{
+		"error":                        {Type: "string"},
+		"time.Time":                    {Type: "string"},
+		"time.Duration":                {Type: "number"},
+		"encoding/json.Number":         {Type: "number"},
+		"encoding/json.RawMessage":     {Type: "mixed"},
+		"encoding/json/jsontext.Value": {Type: "mixed"},
+		"math/big.Int":                 {Type: "number"},
+		"database/sql.NullString":      {Type: "string", Nullable: true},
+		"database/sql.NullBool":        {Type: "boolean", Nullable: true},
+		"database/sql.NullByte":        {Type: "number", Nullable: true},
+		"database/sql.NullInt16":       {Type: "number", Nullable: true},
+		"database/sql.NullInt32":       {Type: "number", Nullable: true},
+		"database/sql.NullInt64":       {Type: "number", Nullable: true},
+		"database/sql.NullFloat64":     {Type: "number", Nullable: true},
+		"database/sql.NullTime":        {Type: "string", Nullable: true},
+		"github.com/google/uuid.UUID":  {Type: "string"},
+	}
+}
+
+// Load adds the types of a JSON config file to the registry, replacing any by the same name.
+// The file is an object of well-known types by name, as in
+// {"github.com/shopspring/decimal.Decimal": {"type": "string"}}
+func (r Registry) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	loaded := Registry{}
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		return err
+	}
+	for name, wk := range loaded {
+		r[name] = wk
+	}
+	return nil
+}
+
+// wellKnown returns what obj is written as when it is in the registry
+func (p *Parse) wellKnown(obj *types.TypeName) (*typeExpr, bool) {
+	name := obj.Name()
+	if obj.Pkg() != nil {
+		name = obj.Pkg().Path() + "." + name
+	}
+	wk, ok := p.WellKnown[name]
+	if !ok {
+		return nil, false
+	}
+	if wk.Import != "" {
+		p.imports[wk.Import] = true
+	}
+	typ := primitive(wk.Type)
+	if wk.Nullable {
+		typ = &typeExpr{kind: KindNullable, elem: typ}
+	}
+	return typ, true
+}
@@ -0,0 +1,235 @@
+package parse
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+)
+
+// Kind is the shape of a resolved type
+type Kind int
+
+const (
+	// KindPrimitive is a builtin such as string, number, boolean or mixed
+	KindPrimitive Kind = iota
+	// KindNamed references another generated type by name
+	KindNamed
+	// KindNullable is a value of Elem that may be null
+	KindNullable
+	// KindArray is a list of Elem
+	KindArray
+	// KindMap is an object indexed by Key holding Elem
+	KindMap
+	// KindRaw is written verbatim, such as a flow tag override
+	KindRaw
+	// KindLiteral is a single constant value, written as Name
+	KindLiteral
+	// KindUnion is any one of Members
+	KindUnion
+	// KindObject is an anonymous struct holding Fields
+	KindObject
+	// KindTuple is exactly Length of Elem
+	KindTuple
+	// KindParam is the type parameter Name of a generic type
+	KindParam
+)
+
+// typeExpr is a Go type resolved through go/types, independent of how it will be written
+type typeExpr struct {
+	kind Kind
+
+	// name is the primitive, the referenced type, the literal, or the raw text
+	name string
+
+	// integer is set for numbers that are Go integers
+	integer bool
+
+	// elem is the element of nullables, arrays, tuples and maps
+	elem *typeExpr
+
+	// length is the length of a tuple
+	length int64
+
+	// key is the key of a map
+	key *typeExpr
+
+	// args are the type arguments of an instantiated generic type
+	args []*typeExpr
+
+	// members are the alternatives of a union
+	members []*typeExpr
+
+	// comment is carried over next to a union member
+	comment string
+
+	// fields are the fields of an object
+	fields []field
+}
+
+func primitive(name string) *typeExpr {
+	return &typeExpr{kind: KindPrimitive, name: name}
+}
+
+// resolve maps a type-checked Go type to the type written out for it
+func (p *Parse) resolve(t types.Type) *typeExpr {
+	if t == nil {
+		return primitive("any")
+	}
+
+	switch x := t.(type) {
+	case *types.Alias:
+		obj := x.Obj()
+		if typ, ok := p.wellKnown(obj); ok {
+			return typ
+		}
+		// Predeclared aliases such as any, and aliases of interfaces, are what they stand for
+		if obj.Pkg() == nil || types.IsInterface(types.Unalias(x)) {
+			return p.resolve(types.Unalias(x))
+		}
+		// Other aliases keep their name, so the chain of names is written
+		return p.instance(obj, x.TypeArgs())
+	case *types.Named:
+		obj := x.Obj()
+		if typ, ok := p.wellKnown(obj); ok {
+			return typ
+		}
+		if iface, ok := x.Underlying().(*types.Interface); ok {
+			if p.unionOf(obj) != nil || p.flowTypeOf(obj) != "" {
+				return p.instance(obj, x.TypeArgs())
+			}
+			return p.anything(x, iface, obj.Pos())
+		}
+		if obj.Pkg() == nil {
+			// Other universe types are only written through the registry
+			return p.resolve(x.Underlying())
+		}
+		return p.instance(obj, x.TypeArgs())
+	case *types.TypeParam:
+		return &typeExpr{kind: KindParam, name: x.Obj().Name()}
+	case *types.Basic:
+		return resolveBasic(x)
+	case *types.Pointer:
+		elem := p.resolve(x.Elem())
+		// Well-known types may already be nullable
+		if elem.kind == KindNullable {
+			return elem
+		}
+		return &typeExpr{kind: KindNullable, elem: elem}
+	case *types.Slice:
+		// encoding/json writes byte slices as base64 strings, but byte arrays as numbers
+		if b, ok := x.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Uint8 {
+			return primitive("string")
+		}
+		return &typeExpr{kind: KindArray, elem: p.element(x.Elem())}
+	case *types.Array:
+		if p.Options.Tuples {
+			return &typeExpr{kind: KindTuple, elem: p.element(x.Elem()), length: x.Len()}
+		}
+		return &typeExpr{kind: KindArray, elem: p.element(x.Elem())}
+	case *types.Map:
+		return &typeExpr{kind: KindMap, key: p.mapKey(x.Key()), elem: p.element(x.Elem())}
+	case *types.Struct:
+		return &typeExpr{kind: KindObject, fields: p.jsonStruct(x, p.Options.MatchEncodingJSON)}
+	case *types.Interface:
+		return p.anything(x, x, token.NoPos)
+	case *types.Signature, *types.Chan:
+		// encoding/json can't write these, so they are only written as a placeholder
+		return primitive("mixed")
+	default:
+		return &typeExpr{kind: KindRaw, name: types.TypeString(t, nil)}
+	}
+}
+
+// anything resolves an interface, which may hold any JSON value. Interfaces with methods
+// are diagnosed once, since what is written depends on the types implementing them.
+func (p *Parse) anything(t types.Type, iface *types.Interface, pos token.Pos) *typeExpr {
+	typ := primitive("mixed")
+	if p.Options.AnyInterfaces {
+		typ = primitive("any")
+	}
+	if iface.NumMethods() == 0 || p.interfaces[t] {
+		return typ
+	}
+	p.interfaces[t] = true
+	if !pos.IsValid() {
+		pos = iface.Method(0).Pos()
+	}
+	p.diagnoseAt(pos, types.TypeString(t, nil), "is an interface with methods, so what it holds is unknown and it is written as %s", typ.name)
+	return typ
+}
+
+// instance references obj by name, along with the type arguments of a generic type
+func (p *Parse) instance(obj *types.TypeName, args *types.TypeList) *typeExpr {
+	named := &typeExpr{kind: KindNamed, name: p.nameOf(obj)}
+	for i := 0; i < args.Len(); i++ {
+		named.args = append(named.args, p.resolve(args.At(i)))
+	}
+	return named
+}
+
+// nameOf returns the name obj is written as. Types are named the first time they are
+// seen, and types from outside of p.Files are queued to be parsed. A name already
+// taken by another type is prefixed with the package name, as in shared_Animal.
+func (p *Parse) nameOf(obj *types.TypeName) string {
+	if name, ok := p.names[obj]; ok {
+		return name
+	}
+
+	name := obj.Name()
+	if p.taken[name] && obj.Pkg() != nil {
+		name = obj.Pkg().Name() + "_" + name
+	}
+	name = p.take(name)
+	p.names[obj] = name
+	if !p.declared[obj] {
+		p.queue = append(p.queue, obj)
+	}
+	return name
+}
+
+// take claims name for a type, numbering it as in shared_Animal_2 when it is already taken
+func (p *Parse) take(name string) string {
+	free := name
+	for i := 2; p.taken[free]; i++ {
+		free = fmt.Sprintf("%s_%d", name, i)
+	}
+	p.taken[free] = true
+	return free
+}
+
+// resolveBasic maps Go's predeclared types to their JSON primitive
+func resolveBasic(b *types.Basic) *typeExpr {
+	info := b.Info()
+	switch {
+	case info&types.IsBoolean != 0:
+		return primitive("boolean")
+	case info&types.IsString != 0:
+		return primitive("string")
+	case info&types.IsComplex != 0 || b.Kind() == types.UnsafePointer:
+		// encoding/json can't write these either
+		return primitive("mixed")
+	case info&types.IsInteger != 0:
+		return &typeExpr{kind: KindPrimitive, name: "number", integer: true}
+	case info&types.IsNumeric != 0:
+		return primitive("number")
+	default:
+		return primitive("any")
+	}
+}
+
+// element resolves the element of a slice, array or map, which is only nullable when
+// it is a pointer and NonNullElements isn't set
+func (p *Parse) element(t types.Type) *typeExpr {
+	if p.Options.NonNullElements {
+		t = deref(t)
+	}
+	return p.resolve(t)
+}
+
+// deref strips one level of pointer from t
+func deref(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
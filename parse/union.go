@@ -0,0 +1,152 @@
+package parse
+
+import (
+	"go/constant"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// unionOf returns the types an interface stands for, or nil if it isn't a union. Interfaces
+// with an unexported method are sealed, so every type of their package implementing them
+// is a member. Other interfaces name their members with a // @union Circle Square directive.
+func (p *Parse) unionOf(obj *types.TypeName) []*types.TypeName {
+	if members, ok := p.unionTypes[obj]; ok {
+		return members
+	}
+	p.unionTypes[obj] = nil
+
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok || obj.Pkg() == nil {
+		return nil
+	}
+
+	var members []*types.TypeName
+	if names, ok := p.unionDirective(obj); ok {
+		for _, name := range names {
+			member, ok := obj.Pkg().Scope().Lookup(name).(*types.TypeName)
+			if !ok {
+				p.diagnose(obj, "the @union member %s is not a type of package %s", name, obj.Pkg().Name())
+				continue
+			}
+			if p.written(obj, member) {
+				members = append(members, member)
+			}
+		}
+	} else if sealed(iface) {
+		scope := obj.Pkg().Scope()
+		for _, name := range scope.Names() {
+			member, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || member.IsAlias() || types.IsInterface(member.Type()) {
+				continue
+			}
+			if implements(member, iface) && p.written(obj, member) {
+				members = append(members, member)
+			}
+		}
+		if len(members) == 0 {
+			p.diagnose(obj, "is sealed, but nothing in package %s implements it", obj.Pkg().Name())
+		}
+		// Members are written in the order they are declared
+		sort.Slice(members, func(i, j int) bool {
+			return members[i].Pos() < members[j].Pos()
+		})
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+	p.unionTypes[obj] = members
+	return members
+}
+
+// written reports whether a member of a union is written as a type of its own, diagnosing
+// those that aren't. Unexported structs never are, unless a directive or marshaler says what
+// they are written as.
+func (p *Parse) written(union, member *types.TypeName) bool {
+	if named, ok := member.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		p.diagnose(union, "the member %s is generic, which can't be written without its type arguments, so it is left out", member.Name())
+		return false
+	}
+	if member.Exported() {
+		return true
+	}
+	if _, ok := member.Type().Underlying().(*types.Struct); !ok || p.flowTypeOf(member) != "" {
+		return true
+	}
+	ptr := types.NewPointer(member.Type())
+	if hasMarshaler(ptr, "MarshalJSON") || hasMarshaler(ptr, "MarshalText") {
+		return true
+	}
+	p.diagnose(union, "the member %s is an unexported struct, which isn't written, so it is left out", member.Name())
+	return false
+}
+
+// unionDirective returns the member names of a // @union directive on obj
+func (p *Parse) unionDirective(obj *types.TypeName) ([]string, bool) {
+	d, _, ok := p.declOf(obj)
+	if !ok {
+		return nil, false
+	}
+	names, ok := directive(d.doc, "@union")
+	return strings.Fields(names), ok
+}
+
+// sealed reports whether an interface can only be implemented within its own package
+func sealed(iface *types.Interface) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if !iface.Method(i).Exported() {
+			return true
+		}
+	}
+	return false
+}
+
+// implements reports whether a type or its pointer implements iface. Generic types can't be
+// checked until they are instantiated, so they implement it when they have its methods.
+func implements(member *types.TypeName, iface *types.Interface) bool {
+	named, ok := member.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return types.Implements(member.Type(), iface) || types.Implements(types.NewPointer(member.Type()), iface)
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), true, m.Pkg(), m.Name())
+		if _, ok := obj.(*types.Func); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// union resolves the members of a union interface
+func (p *Parse) union(members []*types.TypeName) *typeExpr {
+	union := &typeExpr{kind: KindUnion}
+	for _, m := range members {
+		union.members = append(union.members, p.resolve(m.Type()))
+	}
+	return union
+}
+
+// narrowDiscriminators narrows the Kind field of every union member to a string literal,
+// so Flow can tell the members apart. The literal is the Go name of the member, unless
+// its comment has a // @kind circle directive.
+func (p *Parse) narrowDiscriminators() {
+	for _, members := range p.unionTypes {
+		for _, m := range members {
+			name := p.names[m]
+			kind := m.Name()
+			for _, line := range strings.Split(p.comments[name], "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 2 && fields[0] == "@kind" {
+					kind = fields[1]
+				}
+			}
+			for i, f := range p.mappings[name] {
+				if f.name == "Kind" && f.typ.kind == KindPrimitive && f.typ.name == "string" {
+					p.mappings[name][i].typ = &typeExpr{kind: KindLiteral, name: literal(constant.MakeString(kind))}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"go/types"
+)
+
+// Unserializable is what is done with fields encoding/json can't marshal
+type Unserializable int
+
+const (
+	// SkipUnserializable leaves the fields out
+	SkipUnserializable Unserializable = iota
+	// MixedUnserializable writes the fields as mixed
+	MixedUnserializable
+	// FailUnserializable fails the parse once every type is parsed
+	FailUnserializable
+)
+
+// unserializable returns the part of t that encoding/json can't marshal, or nil when it
+// can marshal all of it: funcs, channels, complex numbers and unsafe pointers, along with
+// slices, arrays, maps and pointers of them. Structs are left to their own fields, and
+// types with a marshaler or a @flowtype directive are taken as they are.
+func (p *Parse) unserializable(t types.Type) types.Type {
+	return p.unserializableIn(t, make(map[types.Type]bool))
+}
+
+func (p *Parse) unserializableIn(t types.Type, seen map[types.Type]bool) types.Type {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	if named, ok := types.Unalias(t).(*types.Named); ok {
+		if hasMarshaler(types.NewPointer(t), "MarshalJSON") || hasMarshaler(types.NewPointer(t), "MarshalText") {
+			return nil
+		}
+		if p.flowTypeOf(named.Obj()) != "" {
+			return nil
+		}
+	}
+	switch x := t.Underlying().(type) {
+	case *types.Signature, *types.Chan:
+		return t
+	case *types.Basic:
+		if x.Info()&types.IsComplex != 0 || x.Kind() == types.UnsafePointer {
+			return t
+		}
+	case *types.Pointer:
+		return p.unserializableIn(x.Elem(), seen)
+	case *types.Slice:
+		return p.unserializableIn(x.Elem(), seen)
+	case *types.Array:
+		return p.unserializableIn(x.Elem(), seen)
+	case *types.Map:
+		return p.unserializableIn(x.Elem(), seen)
+	}
+	return nil
+}
+
+// rejected reports whether a field or type holding bad is left out, once it is diagnosed
+func (p *Parse) rejected(what string, bad types.Type) bool {
+	p.diagnoseAt(p.at, types.TypeString(bad, nil), "can't be marshaled by encoding/json, so %s is %s",
+		what, map[Unserializable]string{
+			SkipUnserializable:  "left out",
+			MixedUnserializable: "written as mixed",
+			FailUnserializable:  "an error",
+		}[p.Options.Unserializable])
+
+	switch p.Options.Unserializable {
+	case MixedUnserializable:
+		return false
+	case FailUnserializable:
+		p.failed++
+	}
+	return true
+}
@@ -2,85 +2,14 @@ package parse
 
 import (
 	"fmt"
-	"sort"
+	"go/constant"
+	"io"
 	"strings"
+	"unicode"
 
 	log "github.com/Sirupsen/logrus"
 )
 
-// WriteStructBody writes the body of a Go type as a Flow type
-func (p *Parse) WriteStructBody(s field, level int) {
-	var name, typ string
-	if s.tags.flow.name != "" {
-		name = s.tags.flow.name
-	} else if s.tags.json == "" {
-		// BUG: This is a patch to get it working. Not sure why this isn't being parsed earlier.
-		name = getTag("json", s.tags.original)
-	} else {
-		name = s.tags.json
-	}
-	if s.tags.flow.typ != "" {
-		typ = s.tags.flow.typ
-	} else {
-		typ = s.typ
-	}
-
-	switch s.typ {
-	case "embedded":
-		if v, ok := p.mappings[s.tags.flow.name]; ok {
-			for _, x := range v {
-				if x.tags.flow.name != "" {
-					name = x.tags.flow.name
-				} else {
-					name = getTag("json", x.tags.original)
-				}
-				if x.typ == "embedded" {
-					p.WriteStructBody(x, 0)
-					continue
-				}
-				if x.tags.flow.typ != "" {
-					typ = x.tags.flow.typ
-				} else {
-					typ = x.typ
-				}
-
-				p.writeLine(name, typ, x.comment, 0)
-			}
-		}
-	case "struct":
-		// Indent the struct key
-		for i := 0; i < level; i++ {
-			p.Write("\t")
-		}
-		p.Write(fmt.Sprintf("\t%s: object {\n", name))
-		for i := range s.children {
-			p.WriteStructBody(s.children[i], level+1)
-		}
-		for i := level; i > 0; i-- {
-			// Indent the ending struct braces
-			for j := level; j > 0; j-- {
-				p.Write("\t")
-			}
-		}
-		p.Write("\t},\n")
-	default:
-		p.writeLine(name, typ, s.comment, level)
-	}
-}
-
-// WriteLine writes one line of a struct body
-func (p *Parse) writeLine(name, t, comment string, level int) {
-	for i := 0; i < level; i++ {
-		// Indent each line the amount of levels it is deep
-		p.Write("\t")
-	}
-	if comment != "" {
-		p.Write(fmt.Sprintf("\t%s: %s,\t// %s", name, t, comment))
-	} else {
-		p.Write(fmt.Sprintf("\t%s: %s,\n", name, t))
-	}
-}
-
 // Write fails the script if any error.
 func (p *Parse) Write(line string) {
 	if _, err := p.outfile.Write([]byte(line)); err != nil {
@@ -88,83 +17,319 @@ func (p *Parse) Write(line string) {
 	}
 }
 
-// WriteDocument writes most of the types to the p.Writer. Should change this to be *ALL* of it, later.
+// WriteDocument writes every type parsed as Flow types
 func (p *Parse) WriteDocument() {
-	p.Write("//@flow\n\n// DO NOT EDIT -- automatically generated by goflow\n\n")
-	removeUnexported(p.mappings)
-	for k, v := range p.mappings {
-		updateTags(v)
-		updateTypes(k, v)
+	if err := p.Emit(FlowEmitter{}); err != nil {
+		log.WithError(err).Fatalln("error writing")
 	}
+}
 
-	for k := range p.baseMappings {
-		p.baseMappings[k] = field{
-			typ:     updateType(p.baseMappings[k].typ),
-			name:    p.baseMappings[k].name,
-			comment: p.baseMappings[k].comment,
-			tags:    p.baseMappings[k].tags,
-		}
+// Emit writes every type parsed with e
+func (p *Parse) Emit(e Emitter) error {
+	doc := p.Document()
+	err := e.Emit(p.outfile, doc)
+	p.Diagnostics = append(p.Diagnostics, doc.Diagnostics...)
+	return err
+}
+
+// FlowEmitter writes Flow types
+type FlowEmitter struct {
+	// Guards writes a function checking a value is of each type at runtime, as in isPerson(x)
+	Guards bool
+}
+
+// Extension is the extension of Flow files
+func (FlowEmitter) Extension() string {
+	return ".js"
+}
+
+// Emit writes doc as Flow types
+func (e FlowEmitter) Emit(w io.Writer, doc *Document) error {
+	jw := &jsWriter{opts: doc.Options, guards: e.Guards}
+	jw.document(doc)
+	_, err := io.WriteString(w, jw.String())
+	return err
+}
+
+// TypeScriptEmitter writes TypeScript types
+type TypeScriptEmitter struct {
+	// Declarations writes a .d.ts file, where constants are declared rather than defined
+	Declarations bool
+}
+
+// Extension is the extension of TypeScript files
+func (TypeScriptEmitter) Extension() string {
+	return ".ts"
+}
+
+// Emit writes doc as TypeScript types
+func (e TypeScriptEmitter) Emit(w io.Writer, doc *Document) error {
+	jw := &jsWriter{opts: doc.Options, ts: true, declarations: e.Declarations}
+	jw.document(doc)
+	_, err := io.WriteString(w, jw.String())
+	return err
+}
+
+// jsWriter writes Flow types, or TypeScript types when ts is set, since they mostly agree
+type jsWriter struct {
+	strings.Builder
+	opts Options
+
+	// ts writes TypeScript rather than Flow
+	ts bool
+
+	// declarations writes a TypeScript .d.ts file
+	declarations bool
+
+	// guards writes a runtime check next to each Flow type
+	guards bool
+
+	// decls are the names of every declaration, which guards call the checks of
+	decls map[string]bool
+
+	// params are the bounds of the type parameters of the declaration being written
+	params map[string]*Type
+}
+
+// document writes a whole document
+func (w *jsWriter) document(doc *Document) {
+	if !w.ts {
+		w.WriteString("//@flow\n\n")
 	}
+	w.WriteString("// DO NOT EDIT -- automatically generated by goflow\n\n")
 
-	// Sort the base types alphabetically
-	sortedBase := []string{}
-	for k := range p.baseMappings {
-		sortedBase = append(sortedBase, k)
+	for _, v := range doc.Imports {
+		w.WriteString(v + "\n")
+	}
+	if len(doc.Imports) > 0 {
+		w.WriteString("\n")
 	}
-	sort.Strings(sortedBase)
 
-	// Sort the structs alphabetically
-	sortedStructs := []string{}
-	for k := range p.mappings {
-		sortedStructs = append(sortedStructs, k)
+	w.decls = make(map[string]bool)
+	for _, d := range doc.Decls {
+		w.decls[d.Name] = true
 	}
-	sort.Strings(sortedStructs)
 
-	for _, v := range sortedBase {
-		if c, ok := p.comments[v]; ok {
-			if strings.Contains(c, "// flowignore") {
-				continue
-			}
-			comment := strings.Replace(c, "\n", "\n// ", -1)
+	for _, d := range doc.Decls {
+		if d.Comment != "" {
+			comment := strings.Replace(d.Comment, "\n", "\n// ", -1)
 			comment = strings.TrimSuffix(comment, `// `)
-			p.Write(fmt.Sprintf("// %s", comment))
+			w.WriteString(fmt.Sprintf("// %s", comment))
 		}
-		p.Write(fmt.Sprintf("export type %s = %s\n\n", p.baseMappings[v].name, p.baseMappings[v].typ))
-	}
 
-	for _, v := range sortedStructs {
-		if len(p.mappings[v]) == 0 {
+		w.params = make(map[string]*Type)
+		for _, tp := range d.TypeParams {
+			w.params[tp.Name] = tp.Bound
+		}
+
+		// TypeScript prefers a @tstype directive, since Flow types aren't always valid TypeScript.
+		// Flow text that isn't a primitive or another declaration is unknown without one.
+		typ := d.Type
+		if tsType := d.Directives["tstype"]; w.ts && tsType != "" {
+			typ = &Type{Kind: KindRaw, Name: tsType}
+		} else if flowType, ok := d.Directives["flowtype"]; w.ts && ok && !w.portable(flowType) {
+			doc.Diagnose(d, "the @flowtype %s may not be TypeScript, so it is written as unknown without a @tstype", flowType)
+			typ = &Type{Kind: KindRaw, Name: "unknown"}
+		}
+
+		if !d.Struct || typ.Kind != KindObject {
+			t := w.typeAt(typ, 0)
+			if !strings.HasPrefix(t, "\n") {
+				t = " " + t
+			}
+			w.WriteString(fmt.Sprintf("export type %s%s =%s\n\n", d.Name, w.typeParams(d.TypeParams), t))
+			if len(d.Enum) > 0 {
+				w.enumObject(d.Name, d.Enum)
+			}
+			if w.guards {
+				w.guardFunc(d)
+			}
 			continue
 		}
 
 		// open and close are the brackets for containing types
 		b := brackets{"{", "}"}
-		if c, ok := p.comments[v]; ok {
+		// Set strict if @strict. TypeScript has no exact objects, so it is left as a comment
+		if d.Strict() && !w.ts {
+			b = brackets{"{|", "|}"}
+		}
+		if w.ts {
+			w.WriteString(fmt.Sprintf("export interface %s%s %s\n", d.Name, w.typeParams(d.TypeParams), b.open))
+		} else {
+			w.WriteString(fmt.Sprintf("export type %s%s = %s\n", d.Name, w.typeParams(d.TypeParams), b.open))
+		}
+		for _, f := range typ.Fields {
+			w.WriteString(w.field(f, 0))
+		}
+		w.WriteString(fmt.Sprintf("%s\n\n", b.close))
+		if w.guards {
+			w.guardFunc(d)
+		}
+	}
+}
 
-			// Ignore flowignore comments if @flowignore
-			if strings.Contains(c, "\n@flowignore\n") {
-				continue
-			}
+// field writes one field of an object, a level deeper than the object
+func (w *jsWriter) field(f Field, level int) string {
+	name := propName(f.Name)
+	if f.Optional {
+		name += "?"
+	}
+	return w.line(name, w.typeAt(f.Type, level+1), f.Comment, level)
+}
 
-			// Set strict if @strict
-			if strings.Contains(c, "\n@strict\n") {
-				b = brackets{"{|", "|}"}
-			}
+// line writes one line of a struct body
+func (w *jsWriter) line(name, t, comment string, level int) string {
+	// Indent each line the amount of levels it is deep
+	indent := strings.Repeat("\t", level)
+	sep := ","
+	if w.ts {
+		sep = ";"
+	}
+	if comment != "" {
+		return fmt.Sprintf("%s\t%s: %s%s\t// %s", indent, name, t, sep, comment)
+	}
+	return fmt.Sprintf("%s\t%s: %s%s\n", indent, name, t, sep)
+}
 
-			comment := strings.Replace(c, "\n", "\n// ", -1)
-			comment = strings.TrimSuffix(comment, `// `)
-			p.Write(fmt.Sprintf("// %s", comment))
+// enumObject writes the Go constants of an enum as an object named after it,
+// so that StatusValues.Active can be used in place of the bare value
+func (w *jsWriter) enumObject(name string, values []EnumValue) {
+	switch {
+	case w.ts && w.declarations:
+		w.WriteString(fmt.Sprintf("export declare const %sValues: {\n", name))
+		for _, v := range values {
+			w.WriteString(fmt.Sprintf("\treadonly %s: %s;\n", v.Name, v.Value))
+		}
+	case w.ts:
+		w.WriteString(fmt.Sprintf("export const %sValues = {\n", name))
+		for _, v := range values {
+			w.WriteString(fmt.Sprintf("\t%s: %s as %s,\n", v.Name, v.Value, name))
 		}
+	default:
+		w.WriteString(fmt.Sprintf("export const %sValues = {\n", name))
+		for _, v := range values {
+			w.WriteString(fmt.Sprintf("\t%s: (%s: %s),\n", v.Name, v.Value, name))
+		}
+	}
+	w.WriteString("}\n\n")
+}
 
-		p.Write(fmt.Sprintf("export type %s = %s\n", v, b.open))
+// portable reports whether the Flow text of a type is also TypeScript, as primitives and
+// other declarations are
+func (w *jsWriter) portable(text string) bool {
+	switch text = strings.TrimSpace(text); text {
+	case "string", "number", "boolean":
+		return true
+	}
+	return w.decls[text]
+}
 
-		for _, s := range p.mappings[v] {
-			p.WriteStructBody(s, 0)
+// propName quotes a property name that is not a valid identifier, such as the json name "-"
+func propName(name string) string {
+	for i, c := range name {
+		if c != '_' && c != '$' && !unicode.IsLetter(c) && (i == 0 || !unicode.IsDigit(c)) {
+			return literal(constant.MakeString(name))
 		}
+	}
+	return name
+}
 
-		p.Write(fmt.Sprintf("%s\n\n", b.close))
+// typeAt writes a type, with the fields of any object within it indented a level deeper than level
+func (w *jsWriter) typeAt(t *Type, level int) string {
+	if t == nil {
+		return "any"
+	}
+	switch t.Kind {
+	case KindNullable:
+		if w.opts.NullUnions || w.ts {
+			return w.typeAt(t.Elem, level) + " | null"
+		}
+		return "?" + w.typeAt(t.Elem, level)
+	case KindArray:
+		return fmt.Sprintf("Array<%s>", w.typeAt(t.Elem, level))
+	case KindTuple:
+		elems := make([]string, t.Length)
+		for i := range elems {
+			elems[i] = w.typeAt(t.Elem, level)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case KindMap:
+		// TypeScript only indexes by string or number, so other keys map over each of their values.
+		// A type parameter that may be anything can't be a key, so it is indexed by string.
+		if w.ts && t.Key.Kind == KindParam && w.params[t.Key.Name] == nil {
+			return fmt.Sprintf("{ [key: string]: %s }", w.typeAt(t.Elem, level))
+		}
+		if w.ts && t.Key.Kind != KindPrimitive {
+			return fmt.Sprintf("Partial<Record<%s, %s>>", w.typeAt(t.Key, level), w.typeAt(t.Elem, level))
+		}
+		return fmt.Sprintf("{ [key: %s]: %s }", w.typeAt(t.Key, level), w.typeAt(t.Elem, level))
+	case KindObject:
+		if len(t.Fields) == 0 {
+			return "{}"
+		}
+		out := "{\n"
+		for _, f := range t.Fields {
+			out += w.field(f, level)
+		}
+		return out + strings.Repeat("\t", level) + "}"
+	case KindUnion:
+		members := make([]string, len(t.Members))
+		commented := false
+		for i := range t.Members {
+			members[i] = w.typeAt(t.Members[i], 0)
+			commented = commented || t.Members[i].Comment != ""
+		}
+		if !commented {
+			return strings.Join(members, " | ")
+		}
+		// Put each member on its own line to keep the comments beside them
+		out := ""
+		for i := range members {
+			out += "\n\t| " + members[i]
+			if t.Members[i].Comment != "" {
+				out += "\t// " + t.Members[i].Comment
+			}
+		}
+		return out
+	case KindNamed:
+		if len(t.Args) == 0 {
+			return t.Name
+		}
+		args := make([]string, len(t.Args))
+		for i := range t.Args {
+			args[i] = w.typeAt(t.Args[i], level)
+		}
+		return t.Name + "<" + strings.Join(args, ", ") + ">"
+	case KindPrimitive:
+		if t.Name == "mixed" && w.ts {
+			return "unknown"
+		}
+		return t.Name
+	default:
+		return t.Name
+	}
+}
 
+// typeParams writes the type parameters of a generic type, as in <T, K: string>
+func (w *jsWriter) typeParams(params []TypeParam) string {
+	if len(params) == 0 {
+		return ""
+	}
+	out := make([]string, len(params))
+	for i, tp := range params {
+		out[i] = tp.Name
+		if tp.Bound != nil && w.ts {
+			out[i] += " extends " + w.typeAt(tp.Bound, 0)
+		} else if tp.Bound != nil {
+			out[i] += ": " + w.typeAt(tp.Bound, 0)
+		}
 	}
+	return "<" + strings.Join(out, ", ") + ">"
+}
+
+// flowType writes a resolved type in Flow syntax, which also tells types apart
+func flowType(t *typeExpr) string {
+	return (&jsWriter{}).typeAt(export(t), 0)
 }
 
 // brackets are the opening and closing brackets for a type/struct
@@ -1,7 +1,15 @@
 package gofixtures
 
 import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/big"
 	"time"
+	"unsafe"
+
+	models "github.com/natdm/goflow/testdata/shared"
+	. "github.com/natdm/goflow/testdata/teams"
 )
 
 // Person has many types and should all convert correctly
@@ -76,10 +84,27 @@ type EmbeddedAnimal2 struct {
 	Age          int           `json:"age"`
 }
 
+// Embeds are promoted the way encoding/json promotes them
+type Embeds struct {
+	*Whatever2                // pointers are followed
+	models.Base               // as are types from other packages
+	models.User `json:"user"` // tagged embeds are nested
+	Payrate                   // embedded non-structs are written under their type name
+	Doohickey   string        `json:"doohickey"`
+	Horse                     // Horse.Doohickey is shadowed by Doohickey, Whatever2 by the shallower one
+}
+
 type Time struct {
 	TheTime time.Time `json:"the_time"`
+	Uptime  Uptime    `json:"uptime"`
 }
 
+// Uptime is a number, regardless of its name
+type Uptime int64
+
+// MaxUptime doesn't make Uptime an enum, since it isn't counted with iota
+const MaxUptime Uptime = 1 << 40
+
 // Animal is anything, but should probably have a master
 // @strict
 type Animal struct {
@@ -98,12 +123,416 @@ type Maps struct {
 	SliceOfMaps   []map[string][]Person `json:"slice_of_map_of_slices"`
 }
 
+// Optionals can be left out by encoding/json
+type Optionals struct {
+	Name    *string   `json:"name,omitempty"`
+	Count   int       `json:"count,omitzero"`
+	Tags    []string  `json:"tags,omitempty"`
+	Animal  Animal    `json:"animal,omitempty"` // structs are never empty, so this is always written
+	Created time.Time `json:"created,omitzero"`
+}
+
+// Identifiers are written as strings to keep their precision in JavaScript
+type Identifiers struct {
+	ID       int64   `json:"id,string"` // ID of the thing
+	ParentID *int64  `json:"parent_id,string,omitempty"`
+	Enabled  bool    `json:"enabled,string"`
+	Children []int64 `json:"children,string"` // the string option only applies to scalars
+}
+
+// Wire is written as encoding/json writes it when matching encoding/json
+type Wire struct {
+	Untagged string
+	Dash     string `json:"-,"`
+	Skipped  string `json:"-"`
+	hidden   string
+	Renamed  string `json:"renamed"`
+	WireA
+	WireB
+}
+
+// WireA and WireB both hold Shared at the same depth, so it is dropped from Wire
+type WireA struct {
+	Shared string
+	OnlyA  int `json:"only_a"`
+}
+
+type WireB struct {
+	Shared string
+	Other  string `json:"renamed"` // shadowed by Wire.Renamed
+}
+
+// Nested holds anonymous structs within slices and maps
+type Nested struct {
+	Items []struct {
+		SKU string `json:"sku"`
+	} `json:"items"`
+	Lookup map[string]*struct {
+		Count int `json:"count"` // how many there are
+	} `json:"lookup"`
+}
+
+// Unnamed has json tags of only options, so its fields keep their Go names
+type Unnamed struct {
+	Count int   `json:",omitempty"`
+	ID    int64 `json:",string"`
+}
+
+// Settings holds a struct that, once hoisted, is named the same as Settings_Inner
+type Settings struct {
+	Inner struct {
+		X int `json:"x"`
+	} `json:"inner"`
+}
+
+// Settings_Inner is declared, so the hoisted struct of Settings is named another way
+type Settings_Inner struct {
+	Y int `json:"y"`
+}
+
+// hashSize is the length of Binary.Hash
+const hashSize = 4
+
+// Binary holds byte slices, raw JSON and fixed size arrays
+type Binary struct {
+	Data  []byte          `json:"data"`
+	Blob  Blob            `json:"blob"`
+	Raw   json.RawMessage `json:"raw"`
+	Point [3]int          `json:"point"`
+	Hash  [hashSize]byte  `json:"hash"` // byte arrays are written as numbers
+}
+
+// Blob is written as base64
+type Blob []byte
+
+// Price holds types that marshal themselves
+type Price struct {
+	Amount Money    `json:"amount" flow:".string"`
+	Total  Money    `json:"total"`
+	Code   Code     `json:"code"`
+	Codes  []*Code  `json:"codes"`
+	Big    *big.Int `json:"big" flow:".string"`
+}
+
+// Money is written by MarshalJSON as a decimal string
+type Money struct {
+	cents int64
+}
+
+// MarshalJSON writes the amount in dollars
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%d.%02d"`, m.cents/100, m.cents%100)), nil
+}
+
+// Code is written by MarshalText on its pointer
+type Code struct {
+	Prefix string `json:"prefix"`
+	Number int    `json:"number"`
+}
+
+// MarshalText writes the code as PREFIX-NUMBER
+func (c *Code) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s-%d", c.Prefix, c.Number)), nil
+}
+
+// WellKnowns are written from the registry rather than parsed
+type WellKnowns struct {
+	Nickname sql.NullString `json:"nickname"`
+	Visits   *sql.NullInt64 `json:"visits"`
+	Score    json.Number    `json:"score"`
+	Balance  big.Int        `json:"balance"`
+	Timeout  time.Duration  `json:"timeout"`
+	Fault    error          `json:"fault"`
+}
+
+// Page is a generic envelope
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next"`
+}
+
+// Keyed holds values by a string or number key
+type Keyed[K ~string | ~int, V any] struct {
+	Values map[K]V `json:"values"`
+}
+
+// Box holds its value within a struct of its own
+type Box[T any] struct {
+	Meta struct {
+		Val  T      `json:"val"`
+		Note string `json:"note"`
+	} `json:"meta"`
+	Tags []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+}
+
+// Dict is a map by any comparable key
+type Dict[K comparable, V any] map[K]V
+
+// Labeled is limited to types with a String method, which can't be written
+type Labeled[T fmt.Stringer] struct {
+	Label T `json:"label"`
+}
+
+// List is a generic slice
+type List[T any] []T
+
+// Envelopes instantiates the generic types
+type Envelopes struct {
+	Users  Page[models.User]  `json:"users"`
+	Pages  Page[Page[int]]    `json:"pages"`
+	IDs    List[int64]        `json:"ids"`
+	Counts Keyed[Access, int] `json:"counts"`
+}
+
+// UserID is an alias, written as a type of its own
+type UserID = string
+
+// AdminID is defined over an alias
+type AdminID UserID
+
+// OwnerID is defined over another defined type
+type OwnerID AdminID
+
+// TeamRef is an alias of a type from another package
+type TeamRef = Team
+
+// Member is defined over a struct from another package
+type Member models.User
+
+// Pair is a generic alias
+type Pair[V any] = Keyed[string, V]
+
+// Aliases reference types through chains of names
+type Aliases struct {
+	User   UserID    `json:"user"`
+	Admin  AdminID   `json:"admin"`
+	Owner  OwnerID   `json:"owner"`
+	Team   TeamRef   `json:"team"`
+	Member Member    `json:"member"`
+	Pairs  Pair[int] `json:"pairs"`
+	Any    any       `json:"any"`
+}
+
+// Dynamic holds values of any type
+type Dynamic struct {
+	Value   interface{}                 `json:"value"`
+	Values  []any                       `json:"values"`
+	Extra   map[string]any              `json:"extra"`
+	Payload Payload                     `json:"payload"`
+	Label   fmt.Stringer                `json:"label"`
+	Shape   interface{ Area() float64 } `json:"shape"`
+}
+
+// Payload is any value at all
+type Payload interface{}
+
+// Shape is sealed, so every type here implementing it is a member
+type Shape interface {
+	isShape()
+}
+
+// Circle is a round Shape
+// @kind circle
+type Circle struct {
+	Kind   string  `json:"kind"`
+	Radius float64 `json:"radius"`
+}
+
+func (Circle) isShape() {}
+
+// Square is a Shape through its pointer
+type Square struct {
+	Kind string  `json:"kind"`
+	Side float64 `json:"side"`
+}
+
+func (*Square) isShape() {}
+
+// hidden is a Shape, but unexported structs are never written, so it is left out
+type hidden struct {
+	Kind string `json:"kind"`
+}
+
+func (hidden) isShape() {}
+
+// Sized is a Shape, but a union can't name a generic type without its type arguments, so Shape leaves it out
+type Sized[T any] struct {
+	Kind string `json:"kind"`
+	Size T      `json:"size"`
+}
+
+func (Sized[T]) isShape() {}
+
+// Event names its members
+// @union Opened Closed
+type Event interface {
+	EventName() string
+}
+
+// Opened is an Event
+type Opened struct {
+	Kind string `json:"kind"`
+	ID   int64  `json:"id"`
+}
+
+// EventName names the event
+func (Opened) EventName() string { return "opened" }
+
+// Closed is an Event
+type Closed struct {
+	Kind   string `json:"kind"`
+	ID     int64  `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// EventName names the event
+func (Closed) EventName() string { return "closed" }
+
+// Drawing holds unions
+type Drawing struct {
+	Shapes []Shape `json:"shapes"`
+	Last   Event   `json:"last"`
+}
+
+// Keys holds maps keyed every way encoding/json allows, and one it doesn't
+type Keys struct {
+	ByID     map[int64]string   `json:"by_id"`
+	ByStatus map[Status]int     `json:"by_status"`
+	ByAccess map[Access]int     `json:"by_access"`
+	ByCoord  map[Coord]int      `json:"by_coord"`
+	ByFloat  map[float64]string `json:"by_float"`
+}
+
+// Coord is written as text, so it can key a map
+type Coord struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// MarshalText writes the coordinate as X,Y
+func (c Coord) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", c.X, c.Y)), nil
+}
+
+// Nullables holds pointers within collections, and collections that may be nil
+type Nullables struct {
+	Pets   []*Animal          `json:"pets"`
+	Owners map[string]*Person `json:"owners"`
+	Grid   [2]*int            `json:"grid"`
+	Tags   []string           `json:"tags,omitempty"`
+	Scores map[string]int     `json:"scores"`
+	Ptr    *string            `json:"ptr"`
+}
+
+// Unserializables holds fields encoding/json can't marshal
+type Unserializables struct {
+	Name     string         `json:"name"`
+	Callback func() string  `json:"callback"`
+	Events   chan int       `json:"events"`
+	Handlers []Handler      `json:"handlers"`
+	Phase    complex128     `json:"phase"`
+	Override func()         `json:"override" flow:".() => void"`
+	Pointer  unsafe.Pointer `json:"pointer"`
+}
+
+// Handler is a func, which encoding/json can't marshal
+type Handler func(string) error
+
+// Location is written as its coordinates, whatever its fields
+// @flowtype {| lat: number, lng: number |}
+// @tstype { lat: number; lng: number }
+// @jsonschema {"type": "object", "properties": {"lat": {"type": "number"}, "lng": {"type": "number"}}, "required": ["lat", "lng"]}
+type Location struct {
+	geohash string
+}
+
+// Opaque is unknown to TypeScript, but Flow and JSON Schema still write its fields
+// @tstype unknown
+type Opaque struct {
+	Note string `json:"note"`
+}
+
+// Version writes itself, and the directive says how
+// @flowtype string
+type Version struct {
+	Major, Minor int
+}
+
+// MarshalJSON writes the version as "major.minor"
+func (v Version) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%d.%d"`, v.Major, v.Minor)), nil
+}
+
+// Release embeds Version, so it writes itself as the version does
+type Release struct {
+	Version
+	Notes string `json:"notes"`
+}
+
+// Stamped embeds time.Time, so it writes itself as the time does
+type Stamped struct {
+	time.Time
+}
+
+// Notifier is a func the client is handed elsewhere
+// @flowtype (message: string) => void
+type Notifier func(string)
+
+// Locator is written as its one implementation
+// @flowtype Location
+type Locator interface {
+	Locate() string
+}
+
+// Places uses types written by directives
+type Places struct {
+	Home    Location   `json:"home"`
+	Visited []Location `json:"visited"`
+	Version Version    `json:"version"`
+	Notify  Notifier   `json:"notify"`
+	Near    Locator    `json:"near"`
+}
+
 // Blank does cool things
 type Blank struct{}
 
 // Payrate should be a number
 type Payrate int
 
+// Status is counted with iota, so it should be a union of its values
+type Status int
+
+const (
+	Active Status = iota + 1
+	Suspended
+	_
+	Deleted
+)
+
+// Level is counted with iota, in the same group as a plain number
+type Level int
+
+// Timeout is a number of seconds, not counted with iota
+type Timeout int
+
+const (
+	LevelLow Level = iota
+	LevelHigh
+	DefaultTimeout Timeout = 30
+)
+
+// Access is a string enum, which should be a union of its values
+type Access string
+
+const (
+	// AccessAdmin can do anything
+	AccessAdmin  Access = "admin"
+	AccessMember Access = "member" // AccessMember can read and write
+	AccessGuest  Access = "guest"
+)
+
 // Errors should be an array of strings
 type Errors []error
 
@@ -140,3 +569,11 @@ type IgnoredComment struct {
 type NoIgnoredComment struct {
 	Something string `json:"something"`
 }
+
+// Account references types from other packages
+type Account struct {
+	Owner   models.User   `json:"owner"`
+	Team    Team          `json:"team"`
+	Admins  []models.User `json:"admins"`
+	Created time.Month    `json:"created"`
+}
@@ -2,33 +2,143 @@
 
 // DO NOT EDIT -- automatically generated by goflow
 
+// Access is a string enum, which should be a union of its values
+export type Access =
+	| 'admin'	// AccessAdmin can do anything
+	| 'member'	// AccessMember can read and write
+	| 'guest'
+
+// AdminID is defined over an alias
+export type AdminID = UserID
+
+// Blob is written as base64
+export type Blob = string
+
+// Code is written by MarshalText on its pointer
+export type Code = string
+
+// Coord is written as text, so it can key a map
+export type Coord = string
+
+// Dict is a map by any comparable key
+export type Dict<K, V> = { [key: K]: V }
+
 // Errors should be an array of strings
 export type Errors = Array<string>
 
+// Event names its members
+// @union Opened Closed
+export type Event = Opened | Closed
+
+// Level is counted with iota, in the same group as a plain number
+export type Level = 0 | 1
+
+// List is a generic slice
+export type List<T> = Array<T>
+
+// Location is written as its coordinates, whatever its fields
+// @flowtype {| lat: number, lng: number |}
+// @tstype { lat: number; lng: number }
+// @jsonschema {"type": "object", "properties": {"lat": {"type": "number"}, "lng": {"type": "number"}}, "required": ["lat", "lng"]}
+export type Location = {| lat: number, lng: number |}
+
+// Locator is written as its one implementation
+// @flowtype Location
+export type Locator = Location
+
 // MapKeyPtr is a string pointer key
-export type MapKeyPtr = { [key: ?string]: Animal }
+export type MapKeyPtr = { [key: string]: Animal }
 
 // MapKeyValPtr is a string pointer key
-export type MapKeyValPtr = { [key: ?string]: ?Animal }
+export type MapKeyValPtr = { [key: string]: ?Animal }
 
 // MapNoPtr is a map of string to Animal, no pointer
 export type MapNoPtr = { [key: string]: Animal }
 
 // MapNumPtr should transform int64 to number
-export type MapNumPtr = { [key: number]: Animal }
+export type MapNumPtr = { [key: string]: Animal }
 
 // MapValPtr is a string pointer value
 export type MapValPtr = { [key: string]: ?Animal }
 
+// Member is defined over a struct from another package
+export type Member = User
+
+// Money is written by MarshalJSON as a decimal string
+export type Money = mixed
+
+// A Month specifies a month of the year (January = 1, ...).
+export type Month = 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12
+
+// Notifier is a func the client is handed elsewhere
+// @flowtype (message: string) => void
+export type Notifier = (message: string) => void
+
+// OwnerID is defined over another defined type
+export type OwnerID = AdminID
+
+// Pair is a generic alias
+export type Pair<V> = Keyed<string, V>
+
 // Payrate should be a number
 export type Payrate = number
 
 // People should be an array of Person
 export type People = Array<Person>
 
+// Release embeds Version, so it writes itself as the version does
+export type Release = string
+
+// Role is only referenced by User
+export type Role = string
+
+// Shape is sealed, so every type here implementing it is a member
+export type Shape = Circle | Square
+
+// Stamped embeds time.Time, so it writes itself as the time does
+export type Stamped = string
+
+// Status is counted with iota, so it should be a union of its values
+export type Status = 1 | 2 | 4
+
 // Strings should be an array of strings
 export type Strings = Array<string>
 
+// TeamRef is an alias of a type from another package
+export type TeamRef = Team
+
+// Timeout is a number of seconds, not counted with iota
+export type Timeout = number
+
+// Uptime is a number, regardless of its name
+export type Uptime = number
+
+// UserID is an alias, written as a type of its own
+export type UserID = string
+
+// Version writes itself, and the directive says how
+// @flowtype string
+export type Version = string
+
+// Account references types from other packages
+export type Account = {
+	owner: User,
+	team: Team,
+	admins: Array<User>,
+	created: Month,
+}
+
+// Aliases reference types through chains of names
+export type Aliases = {
+	user: UserID,
+	admin: AdminID,
+	owner: OwnerID,
+	team: TeamRef,
+	member: Member,
+	pairs: Pair<number>,
+	any: mixed,
+}
+
 // Animal is anything, but should probably have a master
 // @strict
 export type Animal = {|
@@ -36,6 +146,61 @@ export type Animal = {|
 	name: string,
 |}
 
+// Base is embedded from the fixtures package
+export type Base = {
+	id: number,
+}
+
+// Binary holds byte slices, raw JSON and fixed size arrays
+export type Binary = {
+	data: string,
+	blob: Blob,
+	raw: mixed,
+	point: Array<number>,
+	hash: Array<number>,	// byte arrays are written as numbers
+}
+
+// Box holds its value within a struct of its own
+export type Box<T> = {
+	meta: {
+		val: T,
+		note: string,
+	},
+	tags: Array<{
+		name: string,
+	}>,
+}
+
+// Circle is a round Shape
+// @kind circle
+export type Circle = {
+	kind: 'circle',
+	radius: number,
+}
+
+// Closed is an Event
+export type Closed = {
+	kind: 'Closed',
+	id: number,
+	reason: string,
+}
+
+// Drawing holds unions
+export type Drawing = {
+	shapes: Array<Shape>,
+	last: Event,
+}
+
+// Dynamic holds values of any type
+export type Dynamic = {
+	value: mixed,
+	values: Array<mixed>,
+	extra: { [key: string]: mixed },
+	payload: mixed,
+	label: mixed,
+	shape: mixed,
+}
+
 export type EmbeddedAnimal = {
 	breed: string,
 	name: string,
@@ -49,24 +214,79 @@ export type EmbeddedAnimal2 = {
 	name: string,
 	birthday: string,	// birthday comment
 	date: string,
-	duration: string,	// a duration
+	duration: number,	// a duration
 	age: number,
 }
 
+// Embeds are promoted the way encoding/json promotes them
+export type Embeds = {
+	doohickey2: string,	// doohickey two
+	id: number,
+	user: User,
+	Payrate: Payrate,
+	doohickey: string,
+	some_horse_attrib: string,
+}
+
+// Envelopes instantiates the generic types
+export type Envelopes = {
+	users: Page<User>,
+	pages: Page<Page<number>>,
+	ids: List<number>,
+	counts: Keyed<Access, number>,
+}
+
 export type Horse = {
 	some_horse_attrib: string,
 	doohickey: string,
 	doohickey2: string,	// doohickey two
 }
 
+// Identifiers are written as strings to keep their precision in JavaScript
+export type Identifiers = {
+	id: string,	// ID of the thing (int64 encoded as a string)
+	parent_id?: ?string,	// int64 encoded as a string
+	enabled: string,	// bool encoded as a string
+	children: Array<number>,	// the string option only applies to scalars
+}
+
+// Keyed holds values by a string or number key
+export type Keyed<K: string | number, V> = {
+	values: { [key: K]: V },
+}
+
+// Keys holds maps keyed every way encoding/json allows, and one it doesn't
+export type Keys = {
+	by_id: { [key: string]: string },
+	by_status: { [key: string]: number },
+	by_access: { [key: Access]: number },
+	by_coord: { [key: string]: number },
+	by_float: { [key: string]: string },
+}
+
+// Labeled is limited to types with a String method, which can't be written
+export type Labeled<T> = {
+	label: T,
+}
+
 // Maps is for testing maps. These are the hardest part.
 // The maps were not fun.
 export type Maps = {
 	base_map: { [key: string]: Person },
-	base_map_ptr_key: { [key: ?string]: Person },
+	base_map_ptr_key: { [key: string]: Person },
 	base_map_ptr_val: { [key: string]: ?Person },
-	map_of_slice: { [key: string]: Person },
-	slice_of_map_of_slices: Array<Person>,
+	map_of_slice: { [key: string]: Array<Person> },
+	slice_of_map_of_slices: Array<{ [key: string]: Array<Person> }>,
+}
+
+// Nested holds anonymous structs within slices and maps
+export type Nested = {
+	items: Array<{
+		sku: string,
+	}>,
+	lookup: { [key: string]: ?{
+		count: number,	// how many there are
+	} },
 }
 
 // NoIgnoredComment should NOT be ignored since flowignore is not the only
@@ -76,6 +296,43 @@ export type NoIgnoredComment = {
 	something: string,
 }
 
+// Nullables holds pointers within collections, and collections that may be nil
+export type Nullables = {
+	pets: Array<?Animal>,
+	owners: { [key: string]: ?Person },
+	grid: Array<?number>,
+	tags?: Array<string>,
+	scores: { [key: string]: number },
+	ptr: ?string,
+}
+
+// Opaque is unknown to TypeScript, but Flow and JSON Schema still write its fields
+// @tstype unknown
+export type Opaque = {
+	note: string,
+}
+
+// Opened is an Event
+export type Opened = {
+	kind: 'Opened',
+	id: number,
+}
+
+// Optionals can be left out by encoding/json
+export type Optionals = {
+	name?: ?string,
+	count?: number,
+	tags?: Array<string>,
+	animal: Animal,	// structs are never empty, so this is always written
+	created?: string,
+}
+
+// Page is a generic envelope
+export type Page<T> = {
+	items: Array<T>,
+	next: string,
+}
+
 // Person has many types and should all convert correctly
 export type Person = {
 	name: string,	// This is a name comment
@@ -83,18 +340,78 @@ export type Person = {
 	StringOverride: String,	// Override `string` with `String`
 	age64: number,
 	flow_is_awesome: boolean,
-	nullable:  ?string,
+	nullable: ?string,
 	animals_array: Array<Animal>,	// I have no pointer
-	animals_array_ptr:  ?Array<Animal>,	// I am a pointer
-	animals_array_ptr_2: Array<Animal>,	// I hold pointers
+	animals_array_ptr: ?Array<Animal>,	// I am a pointer
+	animals_array_ptr_2: Array<?Animal>,	// I hold pointers
 	payrate: Payrate,
-	hascomma: string,
+	hascomma?: string,
 	some_generator: Generator,
 	has_lots_of_tags: string,
-	inner_struct: Object,	// I have a comment in a nested struct
+	inner_struct: {
+		name: string,
+		age: number,
+		child: {
+			toys: Array<string>,
+			name: string,
+			friends: {
+				name: string,
+				age: number,
+				buddies: { [key: string]: Person },
+				empty_struct: {},
+			},
+		},
+	},	// I have a comment in a nested struct
 	map_data: { [key: string]: number },
 }
 
+// Places uses types written by directives
+export type Places = {
+	home: Location,
+	visited: Array<Location>,
+	version: Version,
+	notify: Notifier,
+	near: Locator,
+}
+
+// Price holds types that marshal themselves
+export type Price = {
+	amount: string,
+	total: Money,
+	code: Code,
+	codes: Array<?Code>,
+	big: string,
+}
+
+// Settings holds a struct that, once hoisted, is named the same as Settings_Inner
+export type Settings = {
+	inner: {
+		x: number,
+	},
+}
+
+// Settings_Inner is declared, so the hoisted struct of Settings is named another way
+export type Settings_Inner = {
+	y: number,
+}
+
+// Sized is a Shape, but a union can't name a generic type without its type arguments, so Shape leaves it out
+export type Sized<T> = {
+	kind: string,
+	size: T,
+}
+
+// Square is a Shape through its pointer
+export type Square = {
+	kind: 'Square',
+	side: number,
+}
+
+// Team is referenced without a package selector
+export type Team = {
+	name: string,
+}
+
 // TestFlowTags is to test all the possible flow flags
 export type TestFlowTags = {
 	person: Person,
@@ -107,6 +424,36 @@ export type TestFlowTags = {
 
 export type Time = {
 	the_time: string,
+	uptime: Uptime,
+}
+
+// Unnamed has json tags of only options, so its fields keep their Go names
+export type Unnamed = {
+	Count?: number,
+	ID: string,	// int64 encoded as a string
+}
+
+// Unserializables holds fields encoding/json can't marshal
+export type Unserializables = {
+	name: string,
+	override: () => void,
+}
+
+// User is referenced through a renamed import
+export type User = {
+	name: string,
+	role: Role,
+	pet: shared_Animal,
+}
+
+// WellKnowns are written from the registry rather than parsed
+export type WellKnowns = {
+	nickname: ?string,
+	visits: ?number,
+	score: number,
+	balance: number,
+	timeout: number,
+	fault: string,
 }
 
 export type Whatever = {
@@ -118,3 +465,24 @@ export type Whatever2 = {
 	doohickey2: string,	// doohickey two
 }
 
+// Wire is written as encoding/json writes it when matching encoding/json
+export type Wire = {
+	'-': string,
+	renamed: string,
+	only_a: number,
+}
+
+// WireA and WireB both hold Shared at the same depth, so it is dropped from Wire
+export type WireA = {
+	only_a: number,
+}
+
+export type WireB = {
+	renamed: string,	// shadowed by Wire.Renamed
+}
+
+// Animal shares its name with the fixtures Animal
+export type shared_Animal = {
+	legs: number,
+}
+
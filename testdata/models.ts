@@ -0,0 +1,484 @@
+// DO NOT EDIT -- automatically generated by goflow
+
+// Access is a string enum, which should be a union of its values
+export type Access =
+	| 'admin'	// AccessAdmin can do anything
+	| 'member'	// AccessMember can read and write
+	| 'guest'
+
+// AdminID is defined over an alias
+export type AdminID = UserID
+
+// Blob is written as base64
+export type Blob = string
+
+// Code is written by MarshalText on its pointer
+export type Code = string
+
+// Coord is written as text, so it can key a map
+export type Coord = string
+
+// Dict is a map by any comparable key
+export type Dict<K, V> = { [key: string]: V }
+
+// Errors should be an array of strings
+export type Errors = Array<string>
+
+// Event names its members
+// @union Opened Closed
+export type Event = Opened | Closed
+
+// Level is counted with iota, in the same group as a plain number
+export type Level = 0 | 1
+
+// List is a generic slice
+export type List<T> = Array<T>
+
+// Location is written as its coordinates, whatever its fields
+// @flowtype {| lat: number, lng: number |}
+// @tstype { lat: number; lng: number }
+// @jsonschema {"type": "object", "properties": {"lat": {"type": "number"}, "lng": {"type": "number"}}, "required": ["lat", "lng"]}
+export type Location = { lat: number; lng: number }
+
+// Locator is written as its one implementation
+// @flowtype Location
+export type Locator = Location
+
+// MapKeyPtr is a string pointer key
+export type MapKeyPtr = { [key: string]: Animal }
+
+// MapKeyValPtr is a string pointer key
+export type MapKeyValPtr = { [key: string]: Animal | null }
+
+// MapNoPtr is a map of string to Animal, no pointer
+export type MapNoPtr = { [key: string]: Animal }
+
+// MapNumPtr should transform int64 to number
+export type MapNumPtr = { [key: string]: Animal }
+
+// MapValPtr is a string pointer value
+export type MapValPtr = { [key: string]: Animal | null }
+
+// Member is defined over a struct from another package
+export type Member = User
+
+// Money is written by MarshalJSON as a decimal string
+export type Money = unknown
+
+// A Month specifies a month of the year (January = 1, ...).
+export type Month = 1 | 2 | 3 | 4 | 5 | 6 | 7 | 8 | 9 | 10 | 11 | 12
+
+// Notifier is a func the client is handed elsewhere
+// @flowtype (message: string) => void
+export type Notifier = unknown
+
+// OwnerID is defined over another defined type
+export type OwnerID = AdminID
+
+// Pair is a generic alias
+export type Pair<V> = Keyed<string, V>
+
+// Payrate should be a number
+export type Payrate = number
+
+// People should be an array of Person
+export type People = Array<Person>
+
+// Release embeds Version, so it writes itself as the version does
+export type Release = string
+
+// Role is only referenced by User
+export type Role = string
+
+// Shape is sealed, so every type here implementing it is a member
+export type Shape = Circle | Square
+
+// Stamped embeds time.Time, so it writes itself as the time does
+export type Stamped = string
+
+// Status is counted with iota, so it should be a union of its values
+export type Status = 1 | 2 | 4
+
+// Strings should be an array of strings
+export type Strings = Array<string>
+
+// TeamRef is an alias of a type from another package
+export type TeamRef = Team
+
+// Timeout is a number of seconds, not counted with iota
+export type Timeout = number
+
+// Uptime is a number, regardless of its name
+export type Uptime = number
+
+// UserID is an alias, written as a type of its own
+export type UserID = string
+
+// Version writes itself, and the directive says how
+// @flowtype string
+export type Version = string
+
+// Account references types from other packages
+export interface Account {
+	owner: User;
+	team: Team;
+	admins: Array<User>;
+	created: Month;
+}
+
+// Aliases reference types through chains of names
+export interface Aliases {
+	user: UserID;
+	admin: AdminID;
+	owner: OwnerID;
+	team: TeamRef;
+	member: Member;
+	pairs: Pair<number>;
+	any: unknown;
+}
+
+// Animal is anything, but should probably have a master
+// @strict
+export interface Animal {
+	breed: string;
+	name: string;
+}
+
+// Base is embedded from the fixtures package
+export interface Base {
+	id: number;
+}
+
+// Binary holds byte slices, raw JSON and fixed size arrays
+export interface Binary {
+	data: string;
+	blob: Blob;
+	raw: unknown;
+	point: Array<number>;
+	hash: Array<number>;	// byte arrays are written as numbers
+}
+
+// Box holds its value within a struct of its own
+export interface Box<T> {
+	meta: {
+		val: T;
+		note: string;
+	};
+	tags: Array<{
+		name: string;
+	}>;
+}
+
+// Circle is a round Shape
+// @kind circle
+export interface Circle {
+	kind: 'circle';
+	radius: number;
+}
+
+// Closed is an Event
+export interface Closed {
+	kind: 'Closed';
+	id: number;
+	reason: string;
+}
+
+// Drawing holds unions
+export interface Drawing {
+	shapes: Array<Shape>;
+	last: Event;
+}
+
+// Dynamic holds values of any type
+export interface Dynamic {
+	value: unknown;
+	values: Array<unknown>;
+	extra: { [key: string]: unknown };
+	payload: unknown;
+	label: unknown;
+	shape: unknown;
+}
+
+export interface EmbeddedAnimal {
+	breed: string;
+	name: string;
+	some_horse_attrib: string;
+	doohickey: string;
+	doohickey2: string;	// doohickey two
+}
+
+export interface EmbeddedAnimal2 {
+	breed: string;
+	name: string;
+	birthday: string;	// birthday comment
+	date: string;
+	duration: number;	// a duration
+	age: number;
+}
+
+// Embeds are promoted the way encoding/json promotes them
+export interface Embeds {
+	doohickey2: string;	// doohickey two
+	id: number;
+	user: User;
+	Payrate: Payrate;
+	doohickey: string;
+	some_horse_attrib: string;
+}
+
+// Envelopes instantiates the generic types
+export interface Envelopes {
+	users: Page<User>;
+	pages: Page<Page<number>>;
+	ids: List<number>;
+	counts: Keyed<Access, number>;
+}
+
+export interface Horse {
+	some_horse_attrib: string;
+	doohickey: string;
+	doohickey2: string;	// doohickey two
+}
+
+// Identifiers are written as strings to keep their precision in JavaScript
+export interface Identifiers {
+	id: string;	// ID of the thing (int64 encoded as a string)
+	parent_id?: string | null;	// int64 encoded as a string
+	enabled: string;	// bool encoded as a string
+	children: Array<number>;	// the string option only applies to scalars
+}
+
+// Keyed holds values by a string or number key
+export interface Keyed<K extends string | number, V> {
+	values: Partial<Record<K, V>>;
+}
+
+// Keys holds maps keyed every way encoding/json allows, and one it doesn't
+export interface Keys {
+	by_id: { [key: string]: string };
+	by_status: { [key: string]: number };
+	by_access: Partial<Record<Access, number>>;
+	by_coord: { [key: string]: number };
+	by_float: { [key: string]: string };
+}
+
+// Labeled is limited to types with a String method, which can't be written
+export interface Labeled<T> {
+	label: T;
+}
+
+// Maps is for testing maps. These are the hardest part.
+// The maps were not fun.
+export interface Maps {
+	base_map: { [key: string]: Person };
+	base_map_ptr_key: { [key: string]: Person };
+	base_map_ptr_val: { [key: string]: Person | null };
+	map_of_slice: { [key: string]: Array<Person> };
+	slice_of_map_of_slices: Array<{ [key: string]: Array<Person> }>;
+}
+
+// Nested holds anonymous structs within slices and maps
+export interface Nested {
+	items: Array<{
+		sku: string;
+	}>;
+	lookup: { [key: string]: {
+		count: number;	// how many there are
+	} | null };
+}
+
+// NoIgnoredComment should NOT be ignored since flowignore is not the only
+// thing there
+// flowignore will not ignore here
+export interface NoIgnoredComment {
+	something: string;
+}
+
+// Nullables holds pointers within collections, and collections that may be nil
+export interface Nullables {
+	pets: Array<Animal | null>;
+	owners: { [key: string]: Person | null };
+	grid: Array<number | null>;
+	tags?: Array<string>;
+	scores: { [key: string]: number };
+	ptr: string | null;
+}
+
+// Opaque is unknown to TypeScript, but Flow and JSON Schema still write its fields
+// @tstype unknown
+export type Opaque = unknown
+
+// Opened is an Event
+export interface Opened {
+	kind: 'Opened';
+	id: number;
+}
+
+// Optionals can be left out by encoding/json
+export interface Optionals {
+	name?: string | null;
+	count?: number;
+	tags?: Array<string>;
+	animal: Animal;	// structs are never empty, so this is always written
+	created?: string;
+}
+
+// Page is a generic envelope
+export interface Page<T> {
+	items: Array<T>;
+	next: string;
+}
+
+// Person has many types and should all convert correctly
+export interface Person {
+	name: string;	// This is a name comment
+	age: number;
+	StringOverride: String;	// Override `string` with `String`
+	age64: number;
+	flow_is_awesome: boolean;
+	nullable: string | null;
+	animals_array: Array<Animal>;	// I have no pointer
+	animals_array_ptr: Array<Animal> | null;	// I am a pointer
+	animals_array_ptr_2: Array<Animal | null>;	// I hold pointers
+	payrate: Payrate;
+	hascomma?: string;
+	some_generator: Generator;
+	has_lots_of_tags: string;
+	inner_struct: {
+		name: string;
+		age: number;
+		child: {
+			toys: Array<string>;
+			name: string;
+			friends: {
+				name: string;
+				age: number;
+				buddies: { [key: string]: Person };
+				empty_struct: {};
+			};
+		};
+	};	// I have a comment in a nested struct
+	map_data: { [key: string]: number };
+}
+
+// Places uses types written by directives
+export interface Places {
+	home: Location;
+	visited: Array<Location>;
+	version: Version;
+	notify: Notifier;
+	near: Locator;
+}
+
+// Price holds types that marshal themselves
+export interface Price {
+	amount: string;
+	total: Money;
+	code: Code;
+	codes: Array<Code | null>;
+	big: string;
+}
+
+// Settings holds a struct that, once hoisted, is named the same as Settings_Inner
+export interface Settings {
+	inner: {
+		x: number;
+	};
+}
+
+// Settings_Inner is declared, so the hoisted struct of Settings is named another way
+export interface Settings_Inner {
+	y: number;
+}
+
+// Sized is a Shape, but a union can't name a generic type without its type arguments, so Shape leaves it out
+export interface Sized<T> {
+	kind: string;
+	size: T;
+}
+
+// Square is a Shape through its pointer
+export interface Square {
+	kind: 'Square';
+	side: number;
+}
+
+// Team is referenced without a package selector
+export interface Team {
+	name: string;
+}
+
+// TestFlowTags is to test all the possible flow flags
+export interface TestFlowTags {
+	person: Person;
+	persona: Person;
+	override_name_b: Person;	// should have new name
+	personc: OverrideTypeA;	// should have original name but overriding type
+	override_name_d: OverrideTypeB;
+	override_name_f: Person;	// should have new name
+}
+
+export interface Time {
+	the_time: string;
+	uptime: Uptime;
+}
+
+// Unnamed has json tags of only options, so its fields keep their Go names
+export interface Unnamed {
+	Count?: number;
+	ID: string;	// int64 encoded as a string
+}
+
+// Unserializables holds fields encoding/json can't marshal
+export interface Unserializables {
+	name: string;
+	override: () => void;
+}
+
+// User is referenced through a renamed import
+export interface User {
+	name: string;
+	role: Role;
+	pet: shared_Animal;
+}
+
+// WellKnowns are written from the registry rather than parsed
+export interface WellKnowns {
+	nickname: string | null;
+	visits: number | null;
+	score: number;
+	balance: number;
+	timeout: number;
+	fault: string;
+}
+
+export interface Whatever {
+	doohickey: string;
+	doohickey2: string;	// doohickey two
+}
+
+export interface Whatever2 {
+	doohickey2: string;	// doohickey two
+}
+
+// Wire is written as encoding/json writes it when matching encoding/json
+export interface Wire {
+	'-': string;
+	renamed: string;
+	only_a: number;
+}
+
+// WireA and WireB both hold Shared at the same depth, so it is dropped from Wire
+export interface WireA {
+	only_a: number;
+}
+
+export interface WireB {
+	renamed: string;	// shadowed by Wire.Renamed
+}
+
+// Animal shares its name with the fixtures Animal
+export interface shared_Animal {
+	legs: number;
+}
+
@@ -0,0 +1,22 @@
+// Package shared holds types that are referenced from the fixtures package
+package shared
+
+// User is referenced through a renamed import
+type User struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	Pet  Animal `json:"pet"`
+}
+
+// Role is only referenced by User
+type Role string
+
+// Animal shares its name with the fixtures Animal
+type Animal struct {
+	Legs int `json:"legs"`
+}
+
+// Base is embedded from the fixtures package
+type Base struct {
+	ID int64 `json:"id"`
+}
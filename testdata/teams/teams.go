@@ -0,0 +1,7 @@
+// Package teams is dot imported from the fixtures package
+package teams
+
+// Team is referenced without a package selector
+type Team struct {
+	Name string `json:"name"`
+}
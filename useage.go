@@ -18,8 +18,55 @@ func usage() {
 			example: 	-out= ../src/appname/models/
 						-out= ../src/appname/models/customname.js
 			default: 	"./models". 
+		-lang	The emitter to write types with, flow, ts for TypeScript, jsonschema or openapi
+			example:	-lang= ts
+						-lang= ts -out= ../src/appname/models/models.d.ts
+						-lang= jsonschema
+						-lang= openapi -out= ../api/components.yaml
+			default:	"flow", saved to models.js. ts is saved to models.ts, jsonschema to models.schema.json
+						and openapi to models.json, or YAML when -out names a .yaml or .yml file
+		-guards	Writes a function checking a value is of each Flow type at runtime, as in isPerson(x)
+			example:	-guards= true
+			default:	"false"
 		-r	Transcends directories
 			example:	-recursive= false
 			default:	"true"
+		-enums	Writes an object of the Go constant names next to each enum
+			example:	-enums= true
+			default:	"false"
+		-nonnull-optionals	Writes omitempty and omitzero pointers as name?: T instead of name?: ?T
+			example:	-nonnull-optionals= true
+			default:	"false"
+		-encoding-json	Writes every field encoding/json writes, untagged fields under their Go name
+			example:	-encoding-json= true
+			default:	"false"
+		-hoist	Writes anonymous structs as types of their own, such as Person_InnerStruct
+			example:	-hoist= true
+			default:	"false"
+		-tuples	Writes fixed size arrays such as [3]int as [number, number, number]
+			example:	-tuples= true
+			default:	"false"
+		-any-interfaces	Writes interface{} and any as any instead of mixed
+			example:	-any-interfaces= true
+			default:	"false"
+		-number-keys	Writes integer map keys as number instead of the string encoding/json writes
+			example:	-number-keys= true
+			default:	"false"
+		-null-unions	Writes nullable types as T | null instead of ?T
+			example:	-null-unions= true
+			default:	"false"
+		-nullable-collections	Writes slice and map fields as nullable, as encoding/json writes nil ones as null
+			example:	-nullable-collections= true
+			default:	"false"
+		-nonnull-elements	Writes pointers within slices, arrays and maps as Array<T> instead of Array<?T>
+			example:	-nonnull-elements= true
+			default:	"false"
+		-unserializable	What to do with fields encoding/json can't marshal, such as funcs and channels: skip, mixed or error
+			example:	-unserializable= error
+			default:	"skip"
+		-types	A JSON file of well-known types, written as given rather than parsed
+			example:	-types= ./types.json
+			file:	{"github.com/shopspring/decimal.Decimal": {"type": "string"}}
+			default:	time.Time, time.Duration, database/sql.Null*, uuid.UUID and others
 `)
 }